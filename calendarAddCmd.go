@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ben-23-96/go_events_cli/database"
+	"github.com/ben-23-96/go_events_cli/eventinput"
+	"golang.org/x/term"
+)
+
+// repeatable flag collecting the value of each use
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, "; ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+/*
+Handles the calendar add subcommand. Adds the events given by repeated -event flags, a yaml, json or csv file given with -from-file, or piped to stdin, then prints the result of every event.
+Parameters:
+- args: the arguments after add.
+*/
+func handleCalendarAddCmd(args []string) {
+	addCmd := flag.NewFlagSet("calendar add", flag.ExitOnError)
+	addCmd.Usage = func() {
+		fmt.Fprintln(addCmd.Output(), "Usage: calendar add [-atomic] [-repeat rule] [-event \"name=...,date=...[,venue=...][,repeat=...]\"]... [-from-file events.yaml|events.json|events.csv|-] [-format yaml|json|csv]")
+		addCmd.PrintDefaults()
+	}
+	var specs stringList
+	addCmd.Var(&specs, "event", "Event to add as name=...,date=...,venue=..., can be repeated. The date can include a start time or start and end time, eg date=2023-11-05 19:30-23:00.")
+	fromFile := addCmd.String("from-file", "", "Yaml, json or csv file of events to add, - reads from stdin.")
+	atomic := addCmd.Bool("atomic", false, "Add no events if any event is invalid.")
+	repeat := addCmd.String("repeat", "", "Repeat rule for the events that do not have their own, eg \"weekly;byday=TU;until=2027-06-01\". Parts: daily, weekly, monthly or yearly, interval=n, byday=MO,TU (1MO or -1FR for monthly), bymonthday=1,-1, until=YYYY-MM-DD or count=n, except=YYYY-MM-DD,...")
+	format := addCmd.String("format", "", "Format of the events file: "+strings.Join(eventinput.Formats, ", ")+". Default detected from the file extension or content.")
+	addCmd.Parse(args)
+
+	// read events piped to stdin when no other input is given
+	if len(specs) == 0 && *fromFile == "" && !term.IsTerminal(int(os.Stdin.Fd())) {
+		*fromFile = "-"
+	}
+	if len(specs) == 0 && *fromFile == "" {
+		addCmd.Usage()
+		os.Exit(2)
+	}
+
+	entries, err := readEntries(specs, *fromFile, *format)
+	if err != nil {
+		fmt.Printf("Events not added to calendar: %s\n", err)
+		os.Exit(1)
+	}
+
+	db, err := database.InitDB()
+	if err != nil {
+		fmt.Printf("error initializing database: %s\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	var events []database.NewEvent
+	for _, entry := range entries {
+		if entry.Event.Repeat == "" {
+			entry.Event.Repeat = *repeat
+		}
+		events = append(events, entry.Event)
+	}
+	report, err := database.InsertEvents(db, events, *atomic)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// report every event together once the batch is written
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "INPUT\tEVENT\tDATE\tRESULT")
+	for i, result := range report.Results {
+		var text string
+		switch result.Status {
+		case database.Inserted:
+			text = fmt.Sprintf("added as event %d", result.ID)
+		case database.SkippedDuplicate:
+			text = "already in the calendar"
+		case database.Invalid:
+			text = "invalid: " + strings.TrimPrefix(result.Err.Error(), database.ErrInvalidEvent.Error()+": ")
+		case database.RolledBack:
+			text = "not added, batch rolled back"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", entries[i].Source, result.Event.EventName, result.Event.Date, text)
+	}
+	writer.Flush()
+	fmt.Printf("\n%d added, %d already in the calendar, %d invalid\n", report.Inserted, report.Duplicates, report.Invalid)
+	if report.RolledBack {
+		fmt.Println("No events added, -atomic rolls back every event when any event is invalid")
+	}
+	if report.Invalid > 0 {
+		os.Exit(1)
+	}
+}
+
+/*
+Reads the events to add from the -event flags and the events file.
+Returns:
+- []eventinput.Entry: the events in the order they were given, -event flags first. An -event flag that cannot be parsed gives an event with a ParseErr so it is reported invalid with the other events.
+- error: if the file cannot be read or is not valid.
+*/
+func readEntries(specs []string, fromFile string, format string) ([]eventinput.Entry, error) {
+	var entries []eventinput.Entry
+	for i, spec := range specs {
+		event, err := eventinput.ParseSpec(spec)
+		event.ParseErr = err
+		entries = append(entries, eventinput.Entry{Source: fmt.Sprintf("-event %d", i+1), Event: event})
+	}
+	if fromFile == "" {
+		return entries, nil
+	}
+
+	var r io.Reader = os.Stdin
+	if fromFile != "-" {
+		file, err := os.Open(fromFile)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = file
+		if format == "" {
+			format = eventinput.FormatOf(fromFile)
+		}
+	}
+	fileEntries, err := eventinput.Parse(r, format)
+	if err != nil {
+		return nil, err
+	}
+	return append(entries, fileEntries...), nil
+}
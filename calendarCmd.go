@@ -0,0 +1,298 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ben-23-96/go_events_cli/database"
+	"github.com/ben-23-96/go_events_cli/ics"
+)
+
+// calendar subcommands that take their own arguments and flags, eg calendar update 3 -name "new name"
+var calendarSubcommands = map[string]func(args []string){
+	"list":      handleCalendarListCmd,
+	"calendars": handleCalendarCalendarsCmd,
+	"add":       handleCalendarAddCmd,
+	"delete":    handleCalendarDeleteCmd,
+	"update":    handleCalendarUpdateCmd,
+	"export":    handleCalendarExportCmd,
+	"import":    handleCalendarImportCmd,
+	// adds an event from the last search by the ref printed with it
+	"add-from-search": handleCalendarAddFromSearchCmd,
+	// subscriptions to remote calendars checked for clashes when searching
+	"subscribe":     handleCalendarSubscribeCmd,
+	"unsubscribe":   handleCalendarUnsubscribeCmd,
+	"subscriptions": handleCalendarSubscriptionsCmd,
+	"refresh":       handleCalendarRefreshCmd,
+	// deleted events are kept in the trash, every change can be undone
+	"trash":   handleCalendarTrashCmd,
+	"restore": handleCalendarRestoreCmd,
+	"undo":    handleCalendarUndoCmd,
+}
+
+// formats the calendar can be exported in
+var exportFormats = []string{"ics"}
+
+/*
+Handles the calendar update subcommand. Changes the name, date or venue of the event with the id, only the flags that are provided are changed.
+Parameters:
+- args: the arguments after update, the event id followed by the flags.
+*/
+func handleCalendarUpdateCmd(args []string) {
+	updateCmd := flag.NewFlagSet("calendar update", flag.ExitOnError)
+	updateCmd.Usage = func() {
+		fmt.Fprintln(updateCmd.Output(), "Usage: calendar update <id> [-name name] [-date \"YYYY-MM-DD [HH:MM[-HH:MM]]\"] [-venue venue]")
+		updateCmd.PrintDefaults()
+	}
+	name := updateCmd.String("name", "", "New name of the event.")
+	date := updateCmd.String("date", "", "New date of the event in format YYYY-MM-DD, optionally with a start time or start and end time, eg \"2023-11-05 19:30-23:00\".")
+	venue := updateCmd.String("venue", "", "New venue of the event, an empty venue clears it.")
+
+	id, err := parseEventID(updateCmd, args)
+	if err != nil {
+		fmt.Println(err)
+		updateCmd.Usage()
+		os.Exit(2)
+	}
+
+	// only the flags that were provided are updated
+	var update database.EventUpdate
+	updateCmd.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			update.EventName = name
+		case "date":
+			update.Date = date
+		case "venue":
+			update.Venue = venue
+		}
+	})
+	if update == (database.EventUpdate{}) {
+		fmt.Println("Nothing to update, provide at least one of -name, -date or -venue")
+		os.Exit(2)
+	}
+
+	db, err := database.InitDB()
+	if err != nil {
+		fmt.Printf("error initializing database: %s\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	changed, err := database.UpdateEvent(db, id, update)
+	switch {
+	case errors.Is(err, database.ErrDuplicateEvent), errors.Is(err, database.ErrEventNotFound), errors.Is(err, database.ErrInvalidEvent):
+		fmt.Printf("Event %d not updated: %s\n", id, err)
+		os.Exit(1)
+	case err != nil:
+		fmt.Printf("failed to update event %d: %s\n", id, err)
+		os.Exit(1)
+	case !changed:
+		fmt.Printf("Event %d unchanged, it already has those details\n", id)
+	default:
+		event, _ := database.GetEvent(db, id)
+		fmt.Printf("Event %d updated: %s    %s    %s\n", id, event.EventName, event.When(), event.Venue)
+	}
+}
+
+/*
+Handles the calendar add-from-search subcommand. Adds events from the last search to the calendar by the ref printed with them, keeping the ticket link, city, genre and provider.
+Parameters:
+- args: the refs of the events to add.
+*/
+func handleCalendarAddFromSearchCmd(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: calendar add-from-search <ref> [ref...]")
+		os.Exit(2)
+	}
+	db, err := database.InitDB()
+	if err != nil {
+		fmt.Printf("error initializing database: %s\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	failed := false
+	for _, ref := range args {
+		event, err := database.AddSearchResult(db, ref)
+		if err != nil {
+			fmt.Printf("Event %s not added: %s\n", ref, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("successfully added %s on %s to calender as event %d\n", event.EventName, event.When(), event.ID)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+/*
+Parses the flags of a subcommand that takes an event id, the id can come before or after the flags.
+Returns:
+- int64: the event id.
+- error: if the id is missing or not a number.
+*/
+func parseEventID(flagSet *flag.FlagSet, args []string) (int64, error) {
+	var idArg string
+	// id before the flags, the flag package stops parsing at the first non flag argument
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		idArg = args[0]
+		args = args[1:]
+	}
+	flagSet.Parse(args)
+	if idArg == "" {
+		idArg = flagSet.Arg(0)
+	}
+	if idArg == "" {
+		return 0, errors.New("missing event id")
+	}
+	id, err := strconv.ParseInt(idArg, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid event id %s", idArg)
+	}
+	return id, nil
+}
+
+/*
+Handles the calendar export subcommand. Writes every event in the calendar as an iCalendar file to stdout or the -o file.
+Parameters:
+- args: the arguments after export.
+*/
+func handleCalendarExportCmd(args []string) {
+	exportCmd := flag.NewFlagSet("calendar export", flag.ExitOnError)
+	format := exportCmd.String("format", "ics", "Format to export the calendar in, supported formats are "+strings.Join(exportFormats, ", ")+".")
+	outputFile := exportCmd.String("o", "", "File to write the export to, default stdout.")
+	exportCmd.Parse(args)
+	if *format != "ics" {
+		fmt.Printf("unknown export format %s, supported formats are %s\n", *format, strings.Join(exportFormats, ", "))
+		os.Exit(2)
+	}
+
+	db, err := database.InitDB()
+	if err != nil {
+		fmt.Printf("error initializing database: %s\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	events, err := database.ListEvents(db, database.ListFilter{Series: true})
+	if err != nil {
+		fmt.Printf("Error retrieving events from database. Err: %s\n", err)
+		os.Exit(1)
+	}
+	var icsEvents []ics.Event
+	for _, event := range events {
+		icsEvents = append(icsEvents, toICSEvent(event))
+	}
+
+	var w io.Writer = os.Stdout
+	if *outputFile != "" {
+		file, err := os.Create(*outputFile)
+		if err != nil {
+			fmt.Printf("failed to create %s: %s\n", *outputFile, err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := ics.Write(w, icsEvents); err != nil {
+		fmt.Printf("failed to write export: %s\n", err)
+		os.Exit(1)
+	}
+	if *outputFile != "" {
+		fmt.Printf("Exported %d events to %s\n", len(icsEvents), *outputFile)
+	}
+}
+
+/*
+Handles the calendar import subcommand. Reads the VEVENTs of an iCalendar file, "-" reads from stdin, and imports them into the calendar. Events are matched by UID so importing the same file again only applies changes.
+Parameters:
+- args: the arguments after import, the file to import.
+*/
+func handleCalendarImportCmd(args []string) {
+	importCmd := flag.NewFlagSet("calendar import", flag.ExitOnError)
+	importCmd.Usage = func() {
+		fmt.Fprintln(importCmd.Output(), "Usage: calendar import <file.ics>")
+		importCmd.PrintDefaults()
+	}
+	importCmd.Parse(args)
+	if importCmd.NArg() != 1 {
+		importCmd.Usage()
+		os.Exit(2)
+	}
+
+	var r io.Reader = os.Stdin
+	if fileName := importCmd.Arg(0); fileName != "-" {
+		file, err := os.Open(fileName)
+		if err != nil {
+			fmt.Printf("failed to open %s: %s\n", fileName, err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		r = file
+	}
+	icsEvents, err := ics.Parse(r)
+	if err != nil {
+		fmt.Printf("failed to read calendar file: %s\n", err)
+		os.Exit(1)
+	}
+	var events []database.CalendarEvent
+	for _, icsEvent := range ics.ApplyOverrides(icsEvents) {
+		events = append(events, fromICSEvent(icsEvent))
+	}
+
+	db, err := database.InitDB()
+	if err != nil {
+		fmt.Printf("error initializing database: %s\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	report, err := database.ImportEvents(db, events)
+	if err != nil {
+		fmt.Printf("import failed, no events imported: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%d events in file: %d added, %d updated, %d unchanged, %d skipped\n", len(events), report.Inserted, report.Updated, report.Unchanged, len(report.Errors))
+	for _, err := range report.Errors {
+		fmt.Printf("  skipped: %s\n", err)
+	}
+}
+
+// converts a calendar event to a VEVENT, the location is only the venue so importing the file again leaves the event unchanged
+func toICSEvent(event database.CalendarEvent) ics.Event {
+	return ics.Event{
+		UID:         event.UID,
+		Summary:     event.EventName,
+		Start:       event.Start,
+		End:         event.End,
+		AllDay:      event.AllDay,
+		Location:    event.Venue,
+		URL:         event.URL,
+		Description: event.Description,
+		RRule:       event.RRule,
+		ExDates:     event.ExDates,
+	}
+}
+
+// converts a VEVENT to a calendar event
+func fromICSEvent(event ics.Event) database.CalendarEvent {
+	return database.CalendarEvent{
+		EventName:   event.Summary,
+		Start:       event.Start,
+		End:         event.End,
+		AllDay:      event.AllDay,
+		Venue:       event.Location,
+		UID:         event.UID,
+		URL:         event.URL,
+		Description: event.Description,
+		RRule:       event.RRule,
+		ExDates:     event.ExDates,
+	}
+}
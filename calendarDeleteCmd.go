@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ben-23-96/go_events_cli/database"
+	"github.com/ben-23-96/go_events_cli/eventsearch"
+)
+
+/*
+Handles the calendar delete subcommand. Finds the events matching the -id, -match, -before, -after and -past flags, previews them and deletes them. Deleting more than one event, or an event whose name only matches -match approximately, asks for confirmation unless -yes is given, -dry-run only previews.
+Parameters:
+- args: the arguments after delete.
+*/
+func handleCalendarDeleteCmd(args []string) {
+	deleteCmd := flag.NewFlagSet("calendar delete", flag.ExitOnError)
+	deleteCmd.Usage = func() {
+		fmt.Fprintln(deleteCmd.Output(), "Usage: calendar delete [-id 3,4] [-match pattern] [-before date] [-after date] [-past] [-dry-run] [-yes]")
+		deleteCmd.PrintDefaults()
+	}
+	ids := deleteCmd.String("id", "", "Ids of the events to delete, comma seperated.")
+	match := deleteCmd.String("match", "", "Delete events whose name matches the pattern, a glob such as \"*festival*\" or text matched against similar names.")
+	before := deleteCmd.String("before", "", "Delete events starting before the date, in format YYYY-MM-DD or a relative date such as today or +2w.")
+	after := deleteCmd.String("after", "", "Delete events starting after the date, in format YYYY-MM-DD or a relative date such as today or +2w.")
+	past := deleteCmd.Bool("past", false, "Delete events that started before today.")
+	dryRun := deleteCmd.Bool("dry-run", false, "Show the events that would be deleted without deleting them.")
+	yes := deleteCmd.Bool("yes", false, "Delete without asking for confirmation, which is asked before deleting more than one event or events whose name only approximately matches -match.")
+	deleteCmd.Parse(args)
+
+	now := time.Now()
+	filter, err := deleteFilter(*ids, *match, *before, *after, *past, now)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if filter.IsEmpty() {
+		fmt.Println("No events selected, give at least one of -id, -match, -before, -after or -past")
+		deleteCmd.Usage()
+		os.Exit(2)
+	}
+
+	db, err := database.InitDB()
+	if err != nil {
+		fmt.Printf("error initializing database: %s\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	events, err := database.FindEvents(db, filter, now)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if len(events) == 0 {
+		fmt.Println("No events match, nothing deleted")
+		return
+	}
+
+	fmt.Printf("%s match:\n\n", database.EventCount(int64(len(events))))
+	// a name that only contains the pattern or is similar to it may not be the event meant
+	fuzzy := false
+	for _, event := range events {
+		similar := ""
+		if filter.Match != "" && database.FuzzyMatch(filter.Match, event.EventName) {
+			fuzzy, similar = true, "    (similar name)"
+		}
+		fmt.Printf("%-4d %s    %s    %s%s\n", event.ID, event.EventName, event.When(), event.Venue, similar)
+	}
+	fmt.Println()
+	if *dryRun {
+		fmt.Println("Dry run, nothing deleted")
+		return
+	}
+	if (len(events) > 1 || fuzzy) && !*yes && !confirm(fmt.Sprintf("Delete %s?", database.EventCount(int64(len(events))))) {
+		fmt.Println("Nothing deleted")
+		return
+	}
+
+	var eventIDs []int64
+	for _, event := range events {
+		eventIDs = append(eventIDs, event.ID)
+	}
+	deleted, err := database.DeleteEvents(db, eventIDs)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s deleted\n", database.EventCount(deleted))
+}
+
+/*
+Builds the filter of the events to delete from the flags, dates can be relative to now.
+Returns:
+- database.EventFilter: the filter.
+- error: if an id or date is invalid.
+*/
+func deleteFilter(ids string, match string, before string, after string, past bool, now time.Time) (database.EventFilter, error) {
+	filter := database.EventFilter{Match: strings.TrimSpace(match), Past: past}
+	for _, idArg := range strings.Split(ids, ",") {
+		idArg = strings.TrimSpace(idArg)
+		if idArg == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(idArg, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid event id %s", idArg)
+		}
+		filter.IDs = append(filter.IDs, id)
+	}
+	var err error
+	if before != "" {
+		if filter.Before, err = eventsearch.ResolveDate(before, now, false); err != nil {
+			return filter, fmt.Errorf("invalid -before: %v", err)
+		}
+	}
+	if after != "" {
+		if filter.After, err = eventsearch.ResolveDate(after, now, true); err != nil {
+			return filter, fmt.Errorf("invalid -after: %v", err)
+		}
+	}
+	return filter, nil
+}
+
+// asks a yes or no question on stdin, anything other than y or yes is no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
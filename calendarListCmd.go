@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ben-23-96/go_events_cli/database"
+	"github.com/ben-23-96/go_events_cli/eventsearch"
+)
+
+/*
+Handles the calendar list subcommand. Lists the events of one view: -upcoming (the default), -past, -week or -month, or of the range given with -from and -to, optionally only the events whose name or venue contains -match.
+Parameters:
+- args: the arguments after list.
+*/
+func handleCalendarListCmd(args []string) {
+	listCmd := flag.NewFlagSet("calendar list", flag.ExitOnError)
+	listCmd.Usage = func() {
+		fmt.Fprintln(listCmd.Output(), "Usage: calendar list [-upcoming | -past | -week | -month YYYY-MM | -from date -to date] [-match text] [-limit n]")
+		listCmd.PrintDefaults()
+	}
+	upcoming := listCmd.Bool("upcoming", false, "List the events from today onwards, the default view.")
+	past := listCmd.Bool("past", false, "List the events before today, most recent first.")
+	week := listCmd.Bool("week", false, "List the events of the current week, monday to sunday.")
+	month := listCmd.String("month", "", "List the events of the month in format YYYY-MM, eg 2026-11.")
+	from := listCmd.String("from", "", "List events starting on or after the date, in format YYYY-MM-DD or a relative date such as today or +2w.")
+	to := listCmd.String("to", "", "List events starting on or before the date, in format YYYY-MM-DD or a relative date such as today or +2w.")
+	match := listCmd.String("match", "", "Only list events whose name or venue contains the text.")
+	limit := listCmd.Int("limit", 0, "Maximum number of events to list, 0 lists every event.")
+	listCmd.Parse(args)
+
+	filter, title, err := listFilter(*upcoming, *past, *week, *month, *from, *to, time.Now())
+	if err != nil {
+		fmt.Println(err)
+		listCmd.Usage()
+		os.Exit(2)
+	}
+	filter.Text = *match
+	filter.Limit = *limit
+
+	db, err := database.InitDB()
+	if err != nil {
+		fmt.Printf("error initializing database: %s\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	events, err := database.ListEvents(db, filter)
+	if err != nil {
+		fmt.Printf("Error retrieving events from database. Err: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s:\n\n", title)
+	if len(events) == 0 {
+		fmt.Println("No events")
+		return
+	}
+	for _, event := range events {
+		fmt.Printf("%-4d %s    %s    %s\n", event.ID, event.EventName, event.When(), event.Venue)
+	}
+}
+
+/*
+Builds the filter of a calendar list view, only one view can be given and a view cannot be combined with -from or -to.
+Returns:
+- database.ListFilter: the date range and order of the view.
+- string: the title printed above the events.
+- error: if more than one view is given or a date is invalid.
+*/
+func listFilter(upcoming bool, past bool, week bool, month string, from string, to string, now time.Time) (database.ListFilter, string, error) {
+	views := 0
+	for _, set := range []bool{upcoming, past, week, month != "", from != "" || to != ""} {
+		if set {
+			views++
+		}
+	}
+	if views > 1 {
+		return database.ListFilter{}, "", fmt.Errorf("give only one of -upcoming, -past, -week, -month or -from/-to")
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch {
+	case past:
+		return database.ListFilter{To: today, Order: database.Descending}, "Past Events", nil
+	case week:
+		// weeks start on monday
+		monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		return database.ListFilter{From: monday, To: monday.AddDate(0, 0, 7)}, "Events This Week", nil
+	case month != "":
+		first, err := time.Parse("2006-01", month)
+		if err != nil {
+			return database.ListFilter{}, "", fmt.Errorf("invalid -month %s, expected format YYYY-MM", month)
+		}
+		return database.ListFilter{From: first, To: first.AddDate(0, 1, 0)}, "Events in " + first.Format("January 2006"), nil
+	case from != "" || to != "":
+		var filter database.ListFilter
+		var err error
+		if from != "" {
+			if filter.From, err = eventsearch.ResolveDate(from, now, false); err != nil {
+				return filter, "", fmt.Errorf("invalid -from: %v", err)
+			}
+		}
+		if to != "" {
+			toDate, err := eventsearch.ResolveDate(to, now, true)
+			if err != nil {
+				return filter, "", fmt.Errorf("invalid -to: %v", err)
+			}
+			// the events on the -to date are included
+			filter.To = toDate.AddDate(0, 0, 1)
+		}
+		return filter, "Events", nil
+	}
+	return database.ListFilter{From: today}, "Upcoming Events", nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ben-23-96/go_events_cli/database"
+)
+
+/*
+Handles the calendar trash subcommand. Lists the deleted events in the trash, most recently deleted first, with the ids to restore them by.
+Parameters:
+- args: the arguments after trash.
+*/
+func handleCalendarTrashCmd(args []string) {
+	trashCmd := flag.NewFlagSet("calendar trash", flag.ExitOnError)
+	trashCmd.Usage = func() {
+		fmt.Fprintln(trashCmd.Output(), "Usage: calendar trash")
+		trashCmd.PrintDefaults()
+	}
+	trashCmd.Parse(args)
+
+	db, err := database.InitDB()
+	if err != nil {
+		fmt.Printf("error initializing database: %s\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	events, err := database.GetTrash(db)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if len(events) == 0 {
+		fmt.Println("The trash is empty")
+		return
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tEVENT\tWHEN\tVENUE\tDELETED")
+	for _, event := range events {
+		fmt.Fprintf(writer, "%d\t%s\t%s\t%s\t%s\n", event.ID, event.EventName, event.When(), event.Venue, event.DeletedAt.Local().Format("2006-01-02 15:04"))
+	}
+	writer.Flush()
+	fmt.Println("\nRestore an event with: calendar restore <id>")
+}
+
+/*
+Handles the calendar restore subcommand. Moves the event with the id out of the trash back into the calendar.
+Parameters:
+- args: the arguments after restore, the event id.
+*/
+func handleCalendarRestoreCmd(args []string) {
+	restoreCmd := flag.NewFlagSet("calendar restore", flag.ExitOnError)
+	restoreCmd.Usage = func() {
+		fmt.Fprintln(restoreCmd.Output(), "Usage: calendar restore <id>")
+		restoreCmd.PrintDefaults()
+	}
+	id, err := parseEventID(restoreCmd, args)
+	if err != nil {
+		fmt.Println(err)
+		restoreCmd.Usage()
+		os.Exit(2)
+	}
+
+	db, err := database.InitDB()
+	if err != nil {
+		fmt.Printf("error initializing database: %s\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	event, err := database.RestoreEvent(db, id)
+	if err != nil {
+		fmt.Printf("Event not restored: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Restored %d %s    %s    %s\n", event.ID, event.EventName, event.When(), event.Venue)
+}
+
+/*
+Handles the calendar undo subcommand. Reverts the last add, update, delete, restore or import, each undo goes one change further back. -list shows the recent changes instead.
+Parameters:
+- args: the arguments after undo.
+*/
+func handleCalendarUndoCmd(args []string) {
+	undoCmd := flag.NewFlagSet("calendar undo", flag.ExitOnError)
+	undoCmd.Usage = func() {
+		fmt.Fprintln(undoCmd.Output(), "Usage: calendar undo [-list]")
+		undoCmd.PrintDefaults()
+	}
+	list := undoCmd.Bool("list", false, "List the recent changes to the calendar without undoing any.")
+	undoCmd.Parse(args)
+
+	db, err := database.InitDB()
+	if err != nil {
+		fmt.Printf("error initializing database: %s\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	if *list {
+		entries, err := database.GetJournal(db, 20)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if len(entries) == 0 {
+			fmt.Println("No changes to the calendar yet")
+			return
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "WHEN\tCHANGE\tSTATUS")
+		for _, entry := range entries {
+			status := ""
+			if !entry.UndoneAt.IsZero() {
+				status = "undone"
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\n", entry.CreatedAt.Local().Format("2006-01-02 15:04"), entry.Description, status)
+		}
+		writer.Flush()
+		return
+	}
+
+	entry, err := database.Undo(db)
+	if errors.Is(err, database.ErrNothingToUndo) {
+		fmt.Println("Nothing to undo")
+		return
+	}
+	if err != nil {
+		fmt.Printf("Nothing undone: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Undone: %s (%s)\n", entry.Description, entry.CreatedAt.Local().Format(time.DateTime))
+}
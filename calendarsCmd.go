@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ben-23-96/go_events_cli/database"
+)
+
+/*
+Handles the calendar calendars subcommand. Lists the named calendars in the database directory, marking the one in use.
+Parameters:
+- args: the arguments after calendars.
+*/
+func handleCalendarCalendarsCmd(args []string) {
+	calendarsCmd := flag.NewFlagSet("calendar calendars", flag.ExitOnError)
+	calendarsCmd.Usage = func() {
+		fmt.Fprintln(calendarsCmd.Output(), "Usage: [-db-dir dir] [-calendar name] calendar calendars")
+		calendarsCmd.PrintDefaults()
+	}
+	calendarsCmd.Parse(args)
+
+	dir, err := database.DataDir()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	names, err := database.Calendars()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Printf("Calendars in %s:\n\n", dir)
+	current := database.CurrentCalendar()
+	found := false
+	for _, name := range names {
+		marker := " "
+		if name == current {
+			marker, found = "*", true
+		}
+		fmt.Printf("%s %s\n", marker, name)
+	}
+	if !found {
+		fmt.Printf("* %s (created when first used)\n", current)
+	}
+}
@@ -0,0 +1,166 @@
+package changes
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ben-23-96/go_events_cli/eventsearch"
+)
+
+// Status is how a found event differs from the previous results of a search.
+type Status string
+
+const (
+	// the event was not in the previous results
+	New Status = "new"
+	// the event was in the previous results with a different date, time or ticket link
+	Changed Status = "changed"
+	// the event was in the previous results but has not been found again
+	Removed Status = "removed"
+)
+
+// Change is a found event that is new, changed or removed since the previous results.
+type Change struct {
+	Status Status
+	// the event as it is now, the previous event if it was removed
+	Event eventsearch.FoundEvent
+	// start of a changed event before it moved, zero if it did not move
+	PreviousStart time.Time
+	// ticket links of a changed event before they changed, nil if they did not change
+	PreviousTickets []string
+}
+
+/*
+Diff compares the events found by a search with the previous results of the same search. Events are matched by their fingerprint, an event found on the same date as before is unchanged unless its ticket links changed. An event moved when it is the only event with its fingerprint in both results and its date changed, the dates of a run of several nights that are only in one of the results are new and removed events. Previous events in the past when the search is run are ignored, a search with a moving date window leaves them behind.
+Parameters:
+- previous: []eventsearch.FoundEvent: the events found by the previous run.
+- current: []eventsearch.FoundEvent: the events found now.
+- since: time.Time: previous events before this date are ignored.
+Returns:
+- []Change: the new and changed events in the order of current, followed by the removed events sorted by date.
+*/
+func Diff(previous []eventsearch.FoundEvent, current []eventsearch.FoundEvent, since time.Time) []Change {
+	// unmatched previous events of each fingerprint
+	remaining := make(map[string][]eventsearch.FoundEvent)
+	previousCounts := make(map[string]int)
+	for _, event := range previous {
+		if event.Date.Before(since) {
+			continue
+		}
+		fingerprint := event.Fingerprint()
+		remaining[fingerprint] = append(remaining[fingerprint], event)
+		previousCounts[fingerprint]++
+	}
+	for _, events := range remaining {
+		sortByStart(events)
+	}
+
+	// the previous event of each current event, matched on the same start first
+	matches := make([]*eventsearch.FoundEvent, len(current))
+	for i, event := range current {
+		fingerprint := event.Fingerprint()
+		for j, old := range remaining[fingerprint] {
+			if start(old).Equal(start(event)) {
+				matched := old
+				matches[i] = &matched
+				remaining[fingerprint] = append(remaining[fingerprint][:j], remaining[fingerprint][j+1:]...)
+				break
+			}
+		}
+	}
+	// then an event that moved, only when it is the one event with its fingerprint in both results, a run of several nights that gains and loses nights has new and removed events
+	counts := make(map[string]int)
+	for _, event := range current {
+		counts[event.Fingerprint()]++
+	}
+	for i, event := range current {
+		fingerprint := event.Fingerprint()
+		if matches[i] != nil || counts[fingerprint] != 1 || previousCounts[fingerprint] != 1 {
+			continue
+		}
+		old := remaining[fingerprint][0]
+		matches[i] = &old
+		remaining[fingerprint] = nil
+	}
+
+	var changes []Change
+	for i, event := range current {
+		old := matches[i]
+		if old == nil {
+			changes = append(changes, Change{Status: New, Event: event})
+			continue
+		}
+		change := Change{Status: Changed, Event: event}
+		if !start(*old).Equal(start(event)) {
+			change.PreviousStart = start(*old)
+		}
+		if oldTickets := tickets(*old); strings.Join(oldTickets, " ") != strings.Join(tickets(event), " ") {
+			change.PreviousTickets = oldTickets
+		}
+		if !change.PreviousStart.IsZero() || change.PreviousTickets != nil {
+			changes = append(changes, change)
+		}
+	}
+
+	var removed []eventsearch.FoundEvent
+	for _, events := range remaining {
+		removed = append(removed, events...)
+	}
+	sortByStart(removed)
+	for _, event := range removed {
+		changes = append(changes, Change{Status: Removed, Event: event})
+	}
+	return changes
+}
+
+/*
+Text describes the change, eg "moved from 2023-11-05 19:30" or "new".
+*/
+func (c Change) Text() string {
+	if c.Status != Changed {
+		return string(c.Status)
+	}
+	var parts []string
+	if !c.PreviousStart.IsZero() {
+		parts = append(parts, "moved from "+startText(c.PreviousStart))
+	}
+	if c.PreviousTickets != nil {
+		parts = append(parts, "new ticket link")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// the start of an event, its date when the time is not known
+func start(event eventsearch.FoundEvent) time.Time {
+	if event.Start.IsZero() {
+		return event.Date
+	}
+	return event.Start
+}
+
+// a start as a date, followed by the time when it is not midnight
+func startText(t time.Time) string {
+	if t.Hour() == 0 && t.Minute() == 0 {
+		return t.Format(time.DateOnly)
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+// the ticket links of every listing of an event, sorted so listings found in a different order are the same
+func tickets(event eventsearch.FoundEvent) []string {
+	var links []string
+	for _, source := range event.Sources {
+		links = append(links, source.Tickets)
+	}
+	if len(event.Sources) == 0 {
+		links = append(links, event.Tickets)
+	}
+	sort.Strings(links)
+	return links
+}
+
+// sorts events by start, keeping the order of events that start together
+func sortByStart(events []eventsearch.FoundEvent) {
+	sort.SliceStable(events, func(i, j int) bool { return start(events[i]).Before(start(events[j])) })
+}
@@ -0,0 +1,156 @@
+package changes
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ben-23-96/go_events_cli/eventsearch"
+)
+
+// a found event in Manchester starting at a time given as "2006-01-02 15:04"
+func event(name string, start string, tickets string) eventsearch.FoundEvent {
+	at, err := time.Parse("2006-01-02 15:04", start)
+	if err != nil {
+		panic(err)
+	}
+	return eventsearch.FoundEvent{
+		Name:     name,
+		Date:     time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC),
+		Start:    at,
+		City:     "Manchester",
+		Tickets:  tickets,
+		Provider: "ticketmaster",
+	}
+}
+
+// the event at a venue
+func atVenue(event eventsearch.FoundEvent, venue string) eventsearch.FoundEvent {
+	event.Venue = venue
+	return event
+}
+
+func TestDiff(t *testing.T) {
+	since := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	inLeeds := event("Gig", "2026-11-05 19:00", "https://t/gig")
+	inLeeds.City = "Leeds"
+	merged := event("Gig", "2026-11-05 19:00", "https://t/gig")
+	merged.Sources = []eventsearch.EventSource{{Provider: "ticketmaster", Tickets: "https://t/gig"}, {Provider: "skiddle", Tickets: "https://s/gig"}}
+	mergedReordered := merged
+	mergedReordered.Sources = []eventsearch.EventSource{merged.Sources[1], merged.Sources[0]}
+
+	tests := []struct {
+		name     string
+		previous []eventsearch.FoundEvent
+		current  []eventsearch.FoundEvent
+		want     []string
+	}{
+		{
+			name:    "first run",
+			current: []eventsearch.FoundEvent{event("Gig", "2026-11-05 19:00", "https://t/gig")},
+			want:    []string{"new: Gig 2026-11-05 19:00"},
+		},
+		{
+			name:     "unchanged",
+			previous: []eventsearch.FoundEvent{event("Gig", "2026-11-05 19:00", "https://t/gig")},
+			current:  []eventsearch.FoundEvent{event("Gig", "2026-11-05 19:00", "https://t/gig")},
+		},
+		{
+			name:     "new event",
+			previous: []eventsearch.FoundEvent{event("Gig", "2026-11-05 19:00", "https://t/gig")},
+			current:  []eventsearch.FoundEvent{event("Play", "2026-11-01 14:00", "https://t/play"), event("Gig", "2026-11-05 19:00", "https://t/gig")},
+			want:     []string{"new: Play 2026-11-01 14:00"},
+		},
+		{
+			name:     "moved",
+			previous: []eventsearch.FoundEvent{event("Gig", "2026-11-03 19:00", "https://t/gig")},
+			current:  []eventsearch.FoundEvent{event("Gig", "2026-11-05 19:00", "https://t/gig")},
+			want:     []string{"moved from 2026-11-03 19:00: Gig 2026-11-05 19:00"},
+		},
+		{
+			name:     "new time on the same day",
+			previous: []eventsearch.FoundEvent{event("Gig", "2026-11-05 19:00", "https://t/gig")},
+			current:  []eventsearch.FoundEvent{event("Gig", "2026-11-05 20:30", "https://t/gig")},
+			want:     []string{"moved from 2026-11-05 19:00: Gig 2026-11-05 20:30"},
+		},
+		{
+			name:     "new ticket link",
+			previous: []eventsearch.FoundEvent{event("Gig", "2026-11-05 19:00", "https://t/gig")},
+			current:  []eventsearch.FoundEvent{event("Gig", "2026-11-05 19:00", "https://t/gig-2")},
+			want:     []string{"new ticket link: Gig 2026-11-05 19:00"},
+		},
+		{
+			name:     "moved with a new ticket link",
+			previous: []eventsearch.FoundEvent{event("Gig", "2026-11-03 19:00", "https://t/gig")},
+			current:  []eventsearch.FoundEvent{event("Gig", "2026-11-05 19:00", "https://t/gig-2")},
+			want:     []string{"moved from 2026-11-03 19:00, new ticket link: Gig 2026-11-05 19:00"},
+		},
+		{
+			name:     "gone events sorted by date after the others",
+			previous: []eventsearch.FoundEvent{event("Play", "2026-12-01 14:00", "https://t/play"), event("Quiz", "2026-11-10 20:00", "https://t/quiz")},
+			current:  []eventsearch.FoundEvent{event("Gig", "2026-11-05 19:00", "https://t/gig")},
+			want:     []string{"new: Gig 2026-11-05 19:00", "removed: Quiz 2026-11-10 20:00", "removed: Play 2026-12-01 14:00"},
+		},
+		{
+			name:     "past events are not gone",
+			previous: []eventsearch.FoundEvent{event("Quiz", "2026-10-10 20:00", "https://t/quiz")},
+		},
+		{
+			name:     "name compared normalised",
+			previous: []eventsearch.FoundEvent{event("The Gig!", "2026-11-05 19:00", "https://t/gig")},
+			current:  []eventsearch.FoundEvent{event("the  gig", "2026-11-05 19:00", "https://t/gig")},
+		},
+		{
+			name:     "same name in another city",
+			previous: []eventsearch.FoundEvent{event("Gig", "2026-11-05 19:00", "https://t/gig")},
+			current:  []eventsearch.FoundEvent{inLeeds},
+			want:     []string{"new: Gig 2026-11-05 19:00", "removed: Gig 2026-11-05 19:00"},
+		},
+		{
+			name:     "run that loses a night and gains another",
+			previous: []eventsearch.FoundEvent{event("Quiz", "2026-11-03 20:00", "https://t/quiz-3"), event("Quiz", "2026-11-10 20:00", "https://t/quiz-10")},
+			current:  []eventsearch.FoundEvent{event("Quiz", "2026-11-10 20:00", "https://t/quiz-10"), event("Quiz", "2026-11-17 20:00", "https://t/quiz-17")},
+			want:     []string{"new: Quiz 2026-11-17 20:00", "removed: Quiz 2026-11-03 20:00"},
+		},
+		{
+			name:     "run that moves every night",
+			previous: []eventsearch.FoundEvent{event("Musical", "2026-11-03 19:30", "https://t/musical-3"), event("Musical", "2026-11-04 19:30", "https://t/musical-4")},
+			current:  []eventsearch.FoundEvent{event("Musical", "2026-11-05 19:30", "https://t/musical-5"), event("Musical", "2026-11-06 19:30", "https://t/musical-6")},
+			want:     []string{"new: Musical 2026-11-05 19:30", "new: Musical 2026-11-06 19:30", "removed: Musical 2026-11-03 19:30", "removed: Musical 2026-11-04 19:30"},
+		},
+		{
+			name:     "run that loses a night",
+			previous: []eventsearch.FoundEvent{event("Musical", "2026-11-03 19:30", "https://t/musical-3"), event("Musical", "2026-11-04 19:30", "https://t/musical-4")},
+			current:  []eventsearch.FoundEvent{event("Musical", "2026-11-04 19:30", "https://t/musical-4")},
+			want:     []string{"removed: Musical 2026-11-03 19:30"},
+		},
+		{
+			name:     "same name at other venues in the city",
+			previous: []eventsearch.FoundEvent{atVenue(event("Tribute Night", "2026-11-05 20:00", "https://t/tribute-1"), "Academy")},
+			current:  []eventsearch.FoundEvent{atVenue(event("Tribute Night", "2026-11-05 20:00", "https://t/tribute-1"), "Academy"), atVenue(event("Tribute Night", "2026-11-12 20:00", "https://t/tribute-2"), "Ritz")},
+			want:     []string{"new: Tribute Night 2026-11-12 20:00"},
+		},
+		{
+			name:     "moved to another venue",
+			previous: []eventsearch.FoundEvent{atVenue(event("Gig", "2026-11-05 19:00", "https://t/gig"), "Academy")},
+			current:  []eventsearch.FoundEvent{atVenue(event("Gig", "2026-11-06 19:00", "https://t/gig"), "Ritz")},
+			want:     []string{"new: Gig 2026-11-06 19:00", "removed: Gig 2026-11-05 19:00"},
+		},
+		{
+			name:     "merged listings in another order",
+			previous: []eventsearch.FoundEvent{merged},
+			current:  []eventsearch.FoundEvent{mergedReordered},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, change := range Diff(test.previous, test.current, since) {
+				got = append(got, change.Text()+": "+change.Event.Name+" "+startText(start(change.Event)))
+			}
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package clash
+
+import (
+	"time"
+)
+
+// length assumed for a timed event or busy period that has a start time but no end
+const DefaultDuration = 3 * time.Hour
+
+// Status is how an event relates to the busy periods it was checked against.
+type Status string
+
+const (
+	// no busy period on the same day
+	None Status = "none"
+	// a busy period on the same day but the times do not overlap, or the time of one is not known
+	SameDay Status = "same-day"
+	// the event overlaps a busy period
+	Overlap Status = "clash"
+)
+
+// BusyPeriod is a block of time that is already taken, eg an event in the calendar.
+type BusyPeriod struct {
+	Name  string
+	Start time.Time
+	// exclusive end, zero if not known
+	End time.Time
+	// all day periods block every day from the date of Start to the date before End
+	AllDay bool
+}
+
+// Result is the outcome of checking an event against the busy periods.
+type Result struct {
+	Status Status
+	// busy periods the event overlaps
+	Clashes []BusyPeriod
+	// busy periods on the same day as the event that it does not overlap
+	SameDay []BusyPeriod
+}
+
+/*
+Check compares an event against every busy period and reports all the periods it overlaps and all the periods on the same day that it does not. Times are compared as wall clock times so the event and busy periods must use the same location.
+An all day busy period clashes with anything on its days. An event or timed busy period without an end is assumed to last DefaultDuration. When the time of the event is not known it can only be on the same day as a timed busy period, not clash with it.
+Parameters:
+- start: time.Time: the start of the event, the date alone for all day events.
+- end: time.Time: the exclusive end of the event, zero if not known.
+- allDay: bool: true if the event has no start time.
+- busy: []BusyPeriod: the busy periods to check against.
+Returns:
+- Result: Overlap if any period clashes, otherwise SameDay if any period is on the same day, otherwise None.
+*/
+func Check(start time.Time, end time.Time, allDay bool, busy []BusyPeriod) Result {
+	result := Result{Status: None}
+	eventStart, eventEnd := interval(start, end, allDay)
+	for _, period := range busy {
+		periodStart, periodEnd := interval(period.Start, period.End, period.AllDay)
+		overlaps := eventStart.Before(periodEnd) && periodStart.Before(eventEnd)
+		if !overlaps && !sameDay(eventStart, eventEnd, periodStart, periodEnd) {
+			continue
+		}
+		// only a clash if the times are known or the whole day is blocked
+		if overlaps && (period.AllDay || !allDay) {
+			result.Clashes = append(result.Clashes, period)
+		} else {
+			result.SameDay = append(result.SameDay, period)
+		}
+	}
+	if len(result.Clashes) > 0 {
+		result.Status = Overlap
+	} else if len(result.SameDay) > 0 {
+		result.Status = SameDay
+	}
+	return result
+}
+
+/*
+Returns the interval an event or busy period occupies, whole days for all day periods and DefaultDuration from the start when the end is not known.
+*/
+func interval(start time.Time, end time.Time, allDay bool) (time.Time, time.Time) {
+	if allDay {
+		dayStart := startOfDay(start)
+		if end.IsZero() || !end.After(dayStart) {
+			return dayStart, dayStart.AddDate(0, 0, 1)
+		}
+		// an all day end is the exclusive date after the last day
+		endDay := startOfDay(end)
+		if endDay.Equal(dayStart) {
+			endDay = endDay.AddDate(0, 0, 1)
+		}
+		return dayStart, endDay
+	}
+	if end.IsZero() || !end.After(start) {
+		return start, start.Add(DefaultDuration)
+	}
+	return start, end
+}
+
+// reports whether two intervals touch any of the same calendar days
+func sameDay(aStart time.Time, aEnd time.Time, bStart time.Time, bEnd time.Time) bool {
+	// the last day of an interval is the day of the instant before its exclusive end
+	aLastDay := startOfDay(aEnd.Add(-time.Nanosecond))
+	bLastDay := startOfDay(bEnd.Add(-time.Nanosecond))
+	return !startOfDay(aStart).After(bLastDay) && !startOfDay(bStart).After(aLastDay)
+}
+
+// midnight at the start of the day of t in the location of t
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,64 @@
+package clash
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2026, 11, day, hour, minute, 0, 0, time.UTC)
+	}
+	busy := []BusyPeriod{
+		{Name: "Lunch", Start: at(5, 12, 0)},
+		{Name: "Gig", Start: at(5, 19, 0), End: at(5, 22, 0)},
+		{Name: "Late show", Start: at(7, 23, 0), End: at(8, 1, 0)},
+		{Name: "Holiday", Start: at(10, 0, 0), End: at(12, 0, 0), AllDay: true},
+	}
+	tests := []struct {
+		name    string
+		start   time.Time
+		end     time.Time
+		allDay  bool
+		status  Status
+		clashes []string
+		sameDay []string
+	}{
+		{name: "free day", start: at(6, 20, 0), end: at(6, 22, 0), status: None},
+		{name: "overlap", start: at(5, 20, 0), end: at(5, 21, 0), status: Overlap, clashes: []string{"Gig"}, sameDay: []string{"Lunch"}},
+		{name: "multiple clashes", start: at(5, 11, 0), end: at(5, 23, 0), status: Overlap, clashes: []string{"Lunch", "Gig"}},
+		{name: "same day", start: at(5, 9, 0), end: at(5, 10, 0), status: SameDay, sameDay: []string{"Lunch", "Gig"}},
+		{name: "starts when a period ends", start: at(5, 22, 0), end: at(5, 23, 0), status: SameDay, sameDay: []string{"Lunch", "Gig"}},
+		{name: "no end lasts the default duration", start: at(5, 17, 0), status: Overlap, clashes: []string{"Gig"}, sameDay: []string{"Lunch"}},
+		{name: "period without an end lasts the default duration", start: at(5, 14, 30), end: at(5, 16, 0), status: Overlap, clashes: []string{"Lunch"}, sameDay: []string{"Gig"}},
+		{name: "period past midnight", start: at(8, 0, 30), end: at(8, 2, 0), status: Overlap, clashes: []string{"Late show"}},
+		{name: "all day event with timed periods", start: at(5, 0, 0), allDay: true, status: SameDay, sameDay: []string{"Lunch", "Gig"}},
+		{name: "all day period clashes with a timed event", start: at(11, 10, 0), end: at(11, 12, 0), status: Overlap, clashes: []string{"Holiday"}},
+		{name: "all day events overlap", start: at(9, 0, 0), end: at(11, 0, 0), allDay: true, status: Overlap, clashes: []string{"Holiday"}},
+		{name: "all day period end is exclusive", start: at(12, 0, 0), allDay: true, status: None},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := Check(test.start, test.end, test.allDay, busy)
+			if result.Status != test.status {
+				t.Errorf("status = %s, want %s", result.Status, test.status)
+			}
+			if got, want := names(result.Clashes), strings.Join(test.clashes, ", "); got != want {
+				t.Errorf("clashes = %q, want %q", got, want)
+			}
+			if got, want := names(result.SameDay), strings.Join(test.sameDay, ", "); got != want {
+				t.Errorf("same day = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// the names of busy periods in order
+func names(periods []BusyPeriod) string {
+	var names []string
+	for _, period := range periods {
+		names = append(names, period.Name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ben-23-96/go_events_cli/database"
+	"github.com/ben-23-96/go_events_cli/eventsearch"
+	"github.com/ben-23-96/go_events_cli/output"
+	"gopkg.in/yaml.v3"
+)
+
+// PathEnv is the environment variable giving the path of the config file in place of the default in the XDG config directory.
+const PathEnv = "EVENTS_CLI_CONFIG"
+
+// ErrUnknownKey is returned for a key that is not one of Keys.
+var ErrUnknownKey = errors.New("unknown config key")
+
+// Config is the config file, a yaml file of API keys and the defaults of the flags. The yaml keys are spelt as the names of Keys, eg api-keys.skiddle is skiddle under api-keys.
+type Config struct {
+	APIKeys struct {
+		Ticketmaster string `yaml:"ticketmaster,omitempty"`
+		Skiddle      string `yaml:"skiddle,omitempty"`
+		OpenCage     string `yaml:"opencage,omitempty"`
+	} `yaml:"api-keys,omitempty"`
+	Search struct {
+		Cities string `yaml:"cities,omitempty"`
+		Genres string `yaml:"genres,omitempty"`
+		// the default -date-to, a date or relative date such as +2w
+		Lookahead string `yaml:"lookahead,omitempty"`
+		Providers string `yaml:"providers,omitempty"`
+		Output    string `yaml:"output,omitempty"`
+	} `yaml:"search,omitempty"`
+	Calendar struct {
+		Name  string `yaml:"name,omitempty"`
+		DBDir string `yaml:"db-dir,omitempty"`
+	} `yaml:"calendar,omitempty"`
+}
+
+// Source is where the value of a key came from.
+type Source string
+
+const (
+	FromEnv     Source = "env"
+	FromFile    Source = "config"
+	FromDefault Source = "default"
+)
+
+// Key is a setting that can be read and written with config get and config set.
+type Key struct {
+	Name        string
+	Description string
+	// environment variable that overrides the config file, "" if there is none
+	Env string
+	// built-in default used when the key is not set anywhere
+	Default string
+	// true for API keys, which are masked when listed
+	Secret bool
+	// the field of the Config holding the key
+	field func(c *Config) *string
+	// checks a value before it is written, nil if any value is valid
+	validate func(value string) error
+}
+
+// Keys are the settings of the config file in the order they are listed.
+var Keys = []Key{
+	{Name: "api-keys.ticketmaster", Description: "Ticketmaster API key.", Env: "ticketmasterAPIKey", Secret: true,
+		field: func(c *Config) *string { return &c.APIKeys.Ticketmaster }},
+	{Name: "api-keys.skiddle", Description: "Skiddle API key.", Env: "skiddleAPIKey", Secret: true,
+		field: func(c *Config) *string { return &c.APIKeys.Skiddle }},
+	{Name: "api-keys.opencage", Description: "OpenCage API key used to geocode cities.", Env: "opencageAPIKey", Secret: true,
+		field: func(c *Config) *string { return &c.APIKeys.OpenCage }},
+	{Name: "search.cities", Description: "Default cities searched, comma seperated.", Env: "EVENTS_CLI_CITIES",
+		field: func(c *Config) *string { return &c.Search.Cities }},
+	{Name: "search.genres", Description: "Default genres searched, comma seperated.", Env: "EVENTS_CLI_GENRES",
+		field: func(c *Config) *string { return &c.Search.Genres }},
+	{Name: "search.lookahead", Description: "Default end of the search, a date or relative date such as +2w.", Env: "EVENTS_CLI_LOOKAHEAD", Default: "+1m",
+		field: func(c *Config) *string { return &c.Search.Lookahead }, validate: validateDate},
+	{Name: "search.providers", Description: "Default providers searched, comma seperated.", Env: "EVENTS_CLI_PROVIDERS", Default: strings.Join(eventsearch.ProviderNames(), ","),
+		field: func(c *Config) *string { return &c.Search.Providers }, validate: validateProviders},
+	{Name: "search.output", Description: "Default output format: " + strings.Join(output.Formats, ", ") + ".", Env: "EVENTS_CLI_OUTPUT", Default: "table",
+		field: func(c *Config) *string { return &c.Search.Output }, validate: output.ValidateFormat},
+	{Name: "calendar.name", Description: "Calendar used when -calendar is not given.", Env: database.CalendarEnv, Default: database.DefaultCalendar,
+		field: func(c *Config) *string { return &c.Calendar.Name }},
+	{Name: "calendar.db-dir", Description: "Directory of the calendar databases.", Env: database.DirEnv,
+		field: func(c *Config) *string { return &c.Calendar.DBDir }},
+}
+
+// checks a lookahead can be resolved as the end of a search
+func validateDate(value string) error {
+	_, err := eventsearch.ResolveDate(value, time.Now(), true)
+	return err
+}
+
+// checks every provider in a comma seperated list is registered, names are case insensitive as they are for -providers
+func validateProviders(value string) error {
+	for _, provider := range eventsearch.SplitList(value) {
+		if _, ok := eventsearch.GetProvider(provider); !ok {
+			return fmt.Errorf("unknown provider %s, available providers are %s", provider, strings.Join(eventsearch.ProviderNames(), ", "))
+		}
+	}
+	return nil
+}
+
+/*
+FindKey returns the key with the name.
+Returns:
+- Key: the key.
+- error: ErrUnknownKey if there is no key with the name.
+*/
+func FindKey(name string) (Key, error) {
+	for _, key := range Keys {
+		if key.Name == strings.ToLower(strings.TrimSpace(name)) {
+			return key, nil
+		}
+	}
+	var names []string
+	for _, key := range Keys {
+		names = append(names, key.Name)
+	}
+	sort.Strings(names)
+	return Key{}, fmt.Errorf("%w %s, keys are %s", ErrUnknownKey, name, strings.Join(names, ", "))
+}
+
+/*
+Path returns the path of the config file: EVENTS_CLI_CONFIG if it is set, else go_events_cli/config.yaml in $XDG_CONFIG_HOME or ~/.config.
+Returns:
+- string: the path, the file may not exist.
+- error: if there is no home directory to default to.
+*/
+func Path() (string, error) {
+	if path := os.Getenv(PathEnv); path != "" {
+		return path, nil
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("no directory for the config file, set %s: %v", PathEnv, err)
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "go_events_cli", "config.yaml"), nil
+}
+
+/*
+Load reads the config file, a missing file is an empty config.
+Returns:
+- Config: the config.
+- error: if the file cannot be read or is not valid yaml.
+*/
+func Load() (Config, error) {
+	var c Config
+	path, err := Path()
+	if err != nil {
+		return c, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, fmt.Errorf("failed to read config file: %v", err)
+	}
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	return c, nil
+}
+
+/*
+Save writes the config file, creating its directory. The file is only readable by the user as it holds the API keys.
+Returns:
+- error: if the file cannot be written.
+*/
+func Save(c Config) error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+	return nil
+}
+
+/*
+Value returns the value of a key from the environment variable of the key, else the config file, else the built-in default.
+Returns:
+- string: the value, "" if the key is not set and has no default.
+- Source: where the value came from.
+*/
+func (c *Config) Value(key Key) (string, Source) {
+	if key.Env != "" {
+		if value := os.Getenv(key.Env); value != "" {
+			return value, FromEnv
+		}
+	}
+	if value := *key.field(c); value != "" {
+		return value, FromFile
+	}
+	return key.Default, FromDefault
+}
+
+// Get returns the value of a key in the config file, "" if it is not set.
+func (c *Config) Get(key Key) string {
+	return *key.field(c)
+}
+
+/*
+Set sets the value of a key in the config, an empty value removes the key so its default is used.
+Returns:
+- error: if the value is not valid for the key.
+*/
+func (c *Config) Set(key Key, value string) error {
+	value = strings.TrimSpace(value)
+	if value != "" && key.validate != nil {
+		if err := key.validate(value); err != nil {
+			return fmt.Errorf("invalid %s: %v", key.Name, err)
+		}
+	}
+	*key.field(c) = value
+	return nil
+}
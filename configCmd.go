@@ -0,0 +1,173 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ben-23-96/go_events_cli/config"
+)
+
+// subcommands of the config command
+var configSubcommands = map[string]func(args []string){
+	"get":   handleConfigGetCmd,
+	"set":   handleConfigSetCmd,
+	"unset": handleConfigUnsetCmd,
+	"list":  handleConfigListCmd,
+}
+
+/*
+Handles the config command, reading and writing the config file of API keys and flag defaults.
+Parameters:
+- args: the arguments after config, the subcommand and its arguments.
+*/
+func handleConfigCmd(args []string) {
+	if len(args) > 0 {
+		if handler, ok := configSubcommands[args[0]]; ok {
+			handler(args[1:])
+			return
+		}
+	}
+	fmt.Println("Usage: config get <key> | config set <key> <value> | config unset <key> | config list")
+	os.Exit(2)
+}
+
+/*
+Handles the config get subcommand. Prints the value of a key, from the environment, the config file or the default.
+Parameters:
+- args: the arguments after get, the key.
+*/
+func handleConfigGetCmd(args []string) {
+	key, cfg := configKeyArg("get", args, 1)
+	value, _ := cfg.Value(key)
+	fmt.Println(value)
+}
+
+/*
+Handles the config set subcommand. Writes the value of a key to the config file.
+Parameters:
+- args: the arguments after set, the key and value.
+*/
+func handleConfigSetCmd(args []string) {
+	key, cfg := configKeyArg("set", args, 2)
+	if err := cfg.Set(key, args[1]); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	saveConfig(cfg)
+	if _, source := cfg.Value(key); source == config.FromEnv {
+		fmt.Printf("Set %s, $%s is set and takes precedence over the config file\n", key.Name, key.Env)
+		return
+	}
+	fmt.Printf("Set %s\n", key.Name)
+}
+
+/*
+Handles the config unset subcommand. Removes a key from the config file so its default is used.
+Parameters:
+- args: the arguments after unset, the key.
+*/
+func handleConfigUnsetCmd(args []string) {
+	key, cfg := configKeyArg("unset", args, 1)
+	cfg.Set(key, "")
+	saveConfig(cfg)
+	fmt.Printf("Unset %s\n", key.Name)
+}
+
+/*
+Handles the config list subcommand. Lists every key with its value and where the value comes from, API keys are masked.
+Parameters:
+- args: the arguments after list.
+*/
+func handleConfigListCmd(args []string) {
+	listCmd := flag.NewFlagSet("config list", flag.ExitOnError)
+	showSecrets := listCmd.Bool("show-secrets", false, "Show the API keys rather than masking them.")
+	listCmd.Parse(args)
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if path, err := config.Path(); err == nil {
+		fmt.Printf("Config file: %s\n\n", path)
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "KEY\tVALUE\tSOURCE\tDESCRIPTION")
+	for _, key := range config.Keys {
+		value, source := cfg.Value(key)
+		if key.Secret && !*showSecrets {
+			value = maskSecret(value)
+		}
+		if source == config.FromEnv {
+			source = config.Source("$" + key.Env)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", key.Name, value, source, key.Description)
+	}
+	writer.Flush()
+}
+
+// reads the key argument of a config subcommand and the config file, exiting with the usage if the arguments are wrong
+func configKeyArg(subcommand string, args []string, count int) (config.Key, config.Config) {
+	if len(args) != count {
+		usage := "Usage: config " + subcommand + " <key>"
+		if count == 2 {
+			usage += " <value>"
+		}
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+	key, err := config.FindKey(args[0])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return key, cfg
+}
+
+// writes the config file, exiting if it cannot be written
+func saveConfig(cfg config.Config) {
+	if err := config.Save(cfg); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// hides all but the last 4 characters of an API key
+func maskSecret(value string) string {
+	if len(value) <= 4 {
+		return strings.Repeat("*", len(value))
+	}
+	return strings.Repeat("*", len(value)-4) + value[len(value)-4:]
+}
+
+/*
+Sets the environment variables of the API keys from the config file when they are not already set, so the environment and .env file take precedence over the config.
+*/
+func exportAPIKeys(cfg *config.Config) {
+	for _, key := range config.Keys {
+		if !key.Secret || os.Getenv(key.Env) != "" {
+			continue
+		}
+		if value := cfg.Get(key); value != "" {
+			os.Setenv(key.Env, value)
+		}
+	}
+}
+
+// the value of a config key from the environment, the config file or its default, used as the default of a flag
+func configValue(cfg *config.Config, name string) string {
+	key, err := config.FindKey(name)
+	if err != nil {
+		return ""
+	}
+	value, _ := cfg.Value(key)
+	return value
+}
@@ -1,148 +1,197 @@
 package database
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
-	"os"
-	"sort"
-	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
 )
 
+// errors returned when changing calendar events, check them with errors.Is
+var (
+	ErrEventNotFound  = errors.New("no event with id")
+	ErrDuplicateEvent = errors.New("an event with the same name and start is already in the calendar")
+	ErrInvalidEvent   = errors.New("invalid event")
+)
+
 // CalendarEvent represents an event to be stored in the calendar.
 type CalendarEvent struct {
+	ID        int64
 	EventName string
-	Date      string
+	// date the event starts on in format YYYY-MM-DD
+	Date string
+	// start of the event, midnight for all day events
+	Start time.Time
+	// end of the event, zero if the event has no end
+	End time.Time
+	// true when the event was stored with a date but no time
+	AllDay bool
+	Venue  string
+	// iCalendar uid of the event, imported events keep the uid they were imported with
+	UID         string
+	URL         string
+	Description string
+	// RFC 5545 recurrence rule without the RRULE: prefix, "" if the event does not repeat
+	RRule string
+	// dates a repeating event does not happen on
+	ExDates []time.Time
+	// details of events added from search results, URL is the ticket link
+	City      string
+	Genre     string
+	Provider  string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// when the event was moved to the trash, zero for events in the calendar
+	DeletedAt time.Time
 }
 
-// Initialize and establish a connection to the database
-func InitDB() (*sql.DB, error) {
-	dbFileName := "database/calendar.db"
-	// Check if the database file exists.
-	_, err := os.Stat(dbFileName)
-	if os.IsNotExist(err) {
-		// Database file does not exist, create it.
-		file, err := os.Create(dbFileName)
-		if err != nil {
-			log.Fatal(err)
-		}
-		file.Close()
-	}
-
-	// Open the SQLite database file.
-	db, err := sql.Open("sqlite", dbFileName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %v", err)
-	}
-
-	// Ping the database to check if the connection is valid.
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %v", err)
-	}
-
-	// query to create the table if does not exist
-	query := `
-		CREATE TABLE IF NOT EXISTS CalendarEvents (
-			EventName TEXT,
-			Date TEXT
-		);
-	`
-	// execute the query
-	_, err = db.Exec(query)
-	if err != nil {
-		fmt.Printf("failed to create CalendarEvents table: %v", err)
-		return nil, err
-	}
-
-	fmt.Println("Connected to the database")
-	return db, nil
-}
+// formats of the StartTime and EndTime columns, all day events are stored as a date so both sort as text
+const (
+	storedDateFormat     = time.DateOnly
+	storedDateTimeFormat = "2006-01-02T15:04"
+)
 
 /*
 AddEvents adds a new event to the CalendarEvents table in the sqlite database.
 Parameters:
-- events: a string of comma seperated event names followed by the date they are on. eg event name, date, event 2, date 2
+- events: a string of comma seperated event names followed by the date they are on. eg event name, date, event 2, date 2. Names can contain commas and dates can include a start and end time in any format accepted by ParseEventTime.
 */
 func AddEvents(db *sql.DB, events string) {
-	// split the string into list of names followed by dates
-	commaSplit := strings.Split(events, ", ")
-	// check an even number of items
-	if len(commaSplit)%2 != 0 {
-		fmt.Printf("Events not addded to calendar input string does not contain pairs of event name and date. %s\n", events)
+	// split the string into names followed by dates
+	newEvents, err := ParseEventList(events)
+	if err != nil {
+		fmt.Printf("Events not addded to calendar: %s\n", err)
 		return
 	}
-	// Process each event name and date pair
-	for i := 0; i < len(commaSplit); i += 2 {
-		// get event name and event date
-		eventName := strings.TrimSpace(commaSplit[i])
-		eventDate := strings.TrimSpace(commaSplit[i+1])
-		// Parse the event date
-		date, err := time.Parse("2006-01-02", eventDate)
-		if err != nil {
-			fmt.Printf("Event %s not added to calendar invalid date format %s: %s\n", eventName, eventDate, date)
-			continue
-		}
-		// query to insert event into table
-		query := "INSERT INTO CalendarEvents (EventName, Date) VALUES (?, ?)"
-		// execute the query
-		_, err = db.Exec(query, eventName, eventDate)
-		if err != nil {
-			fmt.Printf("failed to add event to the database: %v", err)
-			continue
+	report, err := InsertEvents(db, newEvents, false)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, result := range report.Results {
+		switch result.Status {
+		case SkippedDuplicate:
+			fmt.Printf("%s on %s is already in the calendar\n", result.Event.EventName, result.Event.Date)
+		case Invalid:
+			fmt.Printf("Event %s not added to calendar: %s\n", result.Event.EventName, result.Err)
+		default:
+			fmt.Printf("successfully added %s on %s to calender\n", result.Event.EventName, result.Event.Date)
 		}
-
-		fmt.Printf("successfully added %s on %s to calender\n", eventName, eventDate)
 	}
 }
 
 /*
-DeleteEvent deletes an event from the CalendarEvents table using the event name.
+DeleteEvent moves every event with the event name to the trash, reporting how many were deleted.
 */
 func DeleteEvent(db *sql.DB, eventName string) {
-	// query to delete a event from the table using the events name
-	query := "DELETE FROM CalendarEvents WHERE EventName = ?"
-	// execute the query
-	_, err := db.Exec(query, eventName)
+	// query the ids of the events with the name
+	rows, err := db.Query("SELECT ID FROM CalendarEvents WHERE EventName = ? AND "+notDeleted, eventName)
 	if err != nil {
 		fmt.Printf("failed to delete event from the database: %v", err)
 		return
 	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			fmt.Printf("failed to delete event from the database: %v", err)
+			return
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	// move the events to the trash
+	deleted, err := DeleteEvents(db, ids)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	switch deleted {
+	case 0:
+		fmt.Printf("No event named %s in the calendar, nothing deleted\n", eventName)
+	case 1:
+		fmt.Println("Event deleted successfully")
+	default:
+		fmt.Printf("%d events named %s deleted successfully\n", deleted, eventName)
+	}
+}
+
+/*
+GetEvent retrieves the event with the id from the CalendarEvents table.
+Returns:
+- CalendarEvent: the event.
+- error: ErrEventNotFound if there is no event with the id or the event is in the trash.
+*/
+func GetEvent(db *sql.DB, id int64) (CalendarEvent, error) {
+	row := db.QueryRow("SELECT "+eventColumns+" FROM CalendarEvents WHERE ID = ? AND "+notDeleted, id)
+	event, err := scanEvent(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return event, fmt.Errorf("%w: %d", ErrEventNotFound, id)
+	}
+	return event, err
+}
+
+// columns selected for a CalendarEvent, in the order scanEvent reads them
+const eventColumns = "ID, EventName, StartTime, COALESCE(EndTime, ''), COALESCE(Venue, ''), COALESCE(UID, ''), COALESCE(URL, ''), COALESCE(Description, ''), COALESCE(RRule, ''), COALESCE(ExDates, ''), COALESCE(City, ''), COALESCE(Genre, ''), COALESCE(Provider, ''), CreatedAt, UpdatedAt, COALESCE(DeletedAt, '')"
+
+// condition selecting the events that are not in the trash
+const notDeleted = "DeletedAt IS NULL"
 
-	fmt.Println("Event deleted successfully")
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
 }
 
 /*
-GetEvents retrieves and returns events on or after current date from the CalendarEvents table.
+Scans a row selected with eventColumns into a CalendarEvent, parsing the stored times.
 */
-func GetEvents(db *sql.DB) ([]CalendarEvent, error) {
-	// query to return all events from the table
-	query := "SELECT EventName, Date FROM CalendarEvents"
-	// execute the query return the rows from table
-	rows, err := db.Query(query)
+func scanEvent(row scanner) (CalendarEvent, error) {
+	var event CalendarEvent
+	var start, end, exDates, createdAt, updatedAt, deletedAt string
+	err := row.Scan(&event.ID, &event.EventName, &start, &end, &event.Venue, &event.UID, &event.URL, &event.Description, &event.RRule, &exDates, &event.City, &event.Genre, &event.Provider, &createdAt, &updatedAt, &deletedAt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query events from the database: %v", err)
+		return event, fmt.Errorf("failed to scan event row: %w", err)
 	}
-	defer rows.Close()
-	// for each returned row put data into a CalendarEvent struct and then append to the events slice
-	var events []CalendarEvent
-	for rows.Next() {
-		var event CalendarEvent
-		err := rows.Scan(&event.EventName, &event.Date)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan event row: %v", err)
-		}
-		events = append(events, event)
+	event.Start, event.AllDay = parseStoredTime(start)
+	event.End, _ = parseStoredTime(end)
+	event.Date = event.Start.Format(time.DateOnly)
+	event.ExDates = parseExDates(exDates)
+	event.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	event.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+	event.DeletedAt, _ = time.Parse(time.RFC3339, deletedAt)
+	return event, nil
+}
+
+/*
+Parses a StartTime or EndTime column value.
+Returns:
+- time.Time: the time, zero if the value is empty or invalid.
+- bool: true if the value is a date without a time.
+*/
+func parseStoredTime(value string) (time.Time, bool) {
+	if t, err := time.Parse(storedDateTimeFormat, value); err == nil {
+		return t, false
 	}
+	t, err := time.Parse(storedDateFormat, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// a random iCalendar uid for an event added to the calendar
+func newUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b) + "@go_events_cli"
+}
 
-	// Sort the events by date
-	sort.Slice(events, func(i, j int) bool {
-		date1, _ := time.Parse(time.DateOnly, events[i].Date)
-		date2, _ := time.Parse(time.DateOnly, events[j].Date)
-		return date1.Before(date2)
-	})
-	//return the events slice
-	return events, nil
+// current time in the format of the CreatedAt and UpdatedAt columns
+func timestamp() string {
+	return time.Now().UTC().Format(time.RFC3339)
 }
@@ -0,0 +1,179 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hbollon/go-edlib"
+)
+
+// minimum levenshtein similarity between a -match pattern and an event name for a fuzzy match
+const matchSimilarityThreshold = 0.8
+
+// EventFilter selects the events to delete, every field that is set must match.
+type EventFilter struct {
+	IDs []int64
+	// glob pattern such as "*festival*", or text matched fuzzily against the name when it has no glob characters
+	Match string
+	// events starting before the date, zero for no limit
+	Before time.Time
+	// events starting after the date, zero for no limit
+	After time.Time
+	// events starting before today
+	Past bool
+}
+
+// IsEmpty reports whether the filter has no conditions, an empty filter would select every event.
+func (f EventFilter) IsEmpty() bool {
+	return len(f.IDs) == 0 && f.Match == "" && f.Before.IsZero() && f.After.IsZero() && !f.Past
+}
+
+/*
+FindEvents returns the events matching the filter ordered by start, used to preview a delete.
+Parameters:
+- filter: EventFilter: the events to find.
+- now: time.Time: the current time, used by Past.
+Returns:
+- []CalendarEvent: the matching events.
+- error: if the filter is empty or the events could not be read.
+*/
+func FindEvents(db *sql.DB, filter EventFilter, now time.Time) ([]CalendarEvent, error) {
+	if filter.IsEmpty() {
+		return nil, fmt.Errorf("%w: no events selected, give an id, a pattern or a date range", ErrInvalidEvent)
+	}
+	// the stored dates and times sort as text so ranges are compared against the date, a date alone sorts before the times on that day
+	var conditions []string
+	var args []any
+	if len(filter.IDs) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(filter.IDs)), ", ")
+		conditions = append(conditions, "ID IN ("+placeholders+")")
+		for _, id := range filter.IDs {
+			args = append(args, id)
+		}
+	}
+	if !filter.Before.IsZero() {
+		conditions = append(conditions, "StartTime < ?")
+		args = append(args, filter.Before.Format(storedDateFormat))
+	}
+	if !filter.After.IsZero() {
+		conditions = append(conditions, "StartTime >= ?")
+		args = append(args, filter.After.AddDate(0, 0, 1).Format(storedDateFormat))
+	}
+	if filter.Past {
+		conditions = append(conditions, "StartTime < ?")
+		args = append(args, now.Format(storedDateFormat))
+	}
+	conditions = append(conditions, notDeleted)
+	query := "SELECT " + eventColumns + " FROM CalendarEvents WHERE " + strings.Join(conditions, " AND ")
+	query += " ORDER BY StartTime, ID"
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events from the database: %v", err)
+	}
+	defer rows.Close()
+
+	var events []CalendarEvent
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		if filter.Match != "" {
+			if matched, _ := matchName(filter.Match, event.EventName); !matched {
+				continue
+			}
+		}
+		// a repeating event is only past once its last occurrence is
+		if event.RRule != "" && ((!filter.Before.IsZero() && !event.endedBefore(filter.Before)) || (filter.Past && !event.endedBefore(now))) {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+/*
+FuzzyMatch reports whether an event name only matches a -match pattern approximately, by containing the pattern or being similar to it, rather than matching a glob pattern or being the same name. Deleting an event that only matches approximately is confirmed first.
+*/
+func FuzzyMatch(pattern string, name string) bool {
+	matched, exact := matchName(pattern, name)
+	return matched && !exact
+}
+
+/*
+Matches an event name against a -match pattern, case insensitive. Patterns with glob characters must match the whole name, other patterns match names that contain them or are similar to them.
+Returns:
+- bool: true if the name matches.
+- bool: true if the name matches the glob pattern or is the same as the pattern.
+*/
+func matchName(pattern string, name string) (bool, bool) {
+	pattern, name = strings.ToLower(strings.TrimSpace(pattern)), strings.ToLower(strings.TrimSpace(name))
+	if strings.ContainsAny(pattern, "*?[") {
+		matched, err := path.Match(pattern, name)
+		return err == nil && matched, err == nil && matched
+	}
+	if name == pattern {
+		return true, true
+	}
+	if strings.Contains(name, pattern) {
+		return true, false
+	}
+	similarity, err := edlib.StringsSimilarity(pattern, name, edlib.Levenshtein)
+	return err == nil && similarity >= matchSimilarityThreshold, false
+}
+
+/*
+DeleteEvents moves the events with the ids to the trash in one transaction, the delete is recorded in the journal so it can be undone.
+Returns:
+- int64: the number of events deleted.
+- error: if the events could not be deleted, none are deleted.
+*/
+func DeleteEvents(db *sql.DB, ids []int64) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	j, err := startJournal(tx, "delete")
+	if err != nil {
+		return 0, err
+	}
+	statement, err := tx.Prepare("UPDATE CalendarEvents SET DeletedAt = ? WHERE ID = ? AND " + notDeleted)
+	if err != nil {
+		return 0, err
+	}
+	defer statement.Close()
+	now := timestamp()
+	var deleted int64
+	for _, id := range ids {
+		// ids that are not in the calendar are left out of the journal
+		var inCalendar bool
+		err := tx.QueryRow("SELECT 1 FROM CalendarEvents WHERE ID = ? AND "+notDeleted, id).Scan(&inCalendar)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete event %d from the database: %v", id, err)
+		}
+		if err := j.changed(id); err != nil {
+			return 0, err
+		}
+		res, err := statement.Exec(now, id)
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete event %d from the database: %v", id, err)
+		}
+		affected, _ := res.RowsAffected()
+		deleted += affected
+	}
+	if err := j.finish(fmt.Sprintf("deleted %s", EventCount(deleted))); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit delete: %v", err)
+	}
+	return deleted, nil
+}
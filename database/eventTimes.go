@@ -0,0 +1,81 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+ParseEventTime parses the date and optional times of a calendar event.
+Accepted formats:
+- YYYY-MM-DD: an all day event.
+- YYYY-MM-DD HH:MM: an event starting at a time, a T can be used in place of the space.
+- YYYY-MM-DD HH:MM-HH:MM: an event with a start and end time, an end before the start is on the next day.
+Returns:
+- time.Time: the start of the event.
+- time.Time: the end of the event, zero if no end time was given.
+- bool: true for an all day event.
+- error: wrapping ErrInvalidEvent if the value is not in one of the formats.
+*/
+func ParseEventTime(value string) (time.Time, time.Time, bool, error) {
+	value = strings.TrimSpace(value)
+	invalid := fmt.Errorf("%w: invalid date format %s, expected YYYY-MM-DD, YYYY-MM-DD HH:MM or YYYY-MM-DD HH:MM-HH:MM", ErrInvalidEvent, value)
+
+	datePart, timePart, hasTime := strings.Cut(strings.Replace(value, "T", " ", 1), " ")
+	date, err := time.Parse(time.DateOnly, datePart)
+	if err != nil {
+		return time.Time{}, time.Time{}, false, invalid
+	}
+	if !hasTime {
+		return date, time.Time{}, true, nil
+	}
+
+	startPart, endPart, hasEnd := strings.Cut(strings.TrimSpace(timePart), "-")
+	start, err := time.Parse(storedDateTimeFormat, datePart+"T"+strings.TrimSpace(startPart))
+	if err != nil {
+		return time.Time{}, time.Time{}, false, invalid
+	}
+	if !hasEnd {
+		return start, time.Time{}, false, nil
+	}
+	end, err := time.Parse(storedDateTimeFormat, datePart+"T"+strings.TrimSpace(endPart))
+	if err != nil {
+		return time.Time{}, time.Time{}, false, invalid
+	}
+	// an event running past midnight
+	if !end.After(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return start, end, false, nil
+}
+
+// formats a start that may not be known for a nullable StartTime column, NULL if the start is not known
+func storedStartTime(start time.Time, allDay bool) any {
+	if start.IsZero() {
+		return nil
+	}
+	return formatStoredTime(start, allDay)
+}
+
+// formats the end of an event for the EndTime column, NULL if the event has no end
+func storedEndTime(end time.Time, allDay bool) any {
+	if end.IsZero() {
+		return nil
+	}
+	return formatStoredTime(end, allDay)
+}
+
+/*
+When returns the date and times of the event in the format accepted by ParseEventTime, eg "2023-11-05 19:30-23:00".
+*/
+func (e CalendarEvent) When() string {
+	if e.AllDay || e.Start.IsZero() {
+		return e.Date
+	}
+	when := e.Start.Format("2006-01-02 15:04")
+	if !e.End.IsZero() {
+		when += "-" + e.End.Format("15:04")
+	}
+	return when
+}
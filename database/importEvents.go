@@ -0,0 +1,126 @@
+package database
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ImportReport counts what happened to each event of an import.
+type ImportReport struct {
+	Inserted  int
+	Updated   int
+	Unchanged int
+	// events that could not be imported, each wraps ErrInvalidEvent or ErrDuplicateEvent
+	Errors []error
+}
+
+/*
+ImportEvents inserts or updates events matching them by UID, so importing the same events again changes nothing. An event in the trash with the UID is restored. The import is recorded in the journal so it can be undone. Events without a UID are given one made from their name and start. The import runs in one transaction, an invalid event or an event with the same name and start as a different event is skipped and reported without stopping the rest of the import.
+Parameters:
+- events: []CalendarEvent: the events to import, ID, Date and the timestamps are ignored.
+Returns:
+- ImportReport: the number of events inserted, updated and unchanged and the errors of the skipped events.
+- error: if the database could not be read or written.
+*/
+func ImportEvents(db *sql.DB, events []CalendarEvent) (ImportReport, error) {
+	var report ImportReport
+	tx, err := db.Begin()
+	if err != nil {
+		return report, fmt.Errorf("failed to start import: %v", err)
+	}
+	defer tx.Rollback()
+	j, err := startJournal(tx, "import")
+	if err != nil {
+		return report, err
+	}
+
+	for _, event := range events {
+		event.EventName = strings.TrimSpace(event.EventName)
+		if event.EventName == "" || event.Start.IsZero() {
+			report.Errors = append(report.Errors, fmt.Errorf("%w: %s has no name or start", ErrInvalidEvent, event.UID))
+			continue
+		}
+		// an all day event that lasts a single day is stored the same way as one added with only a date
+		if event.AllDay && event.End.Equal(event.Start.AddDate(0, 0, 1)) {
+			event.End = time.Time{}
+		}
+		if event.UID == "" {
+			event.UID = generatedUID(event)
+		}
+
+		existing, err := scanEvent(tx.QueryRow("SELECT "+eventColumns+" FROM CalendarEvents WHERE UID = ?", event.UID))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return report, err
+		}
+		found := err == nil
+		if found && existing.DeletedAt.IsZero() && sameDetails(existing, event) {
+			report.Unchanged++
+			continue
+		}
+
+		// the name and start must stay unique among the other events
+		start := formatStoredTime(event.Start, event.AllDay)
+		var duplicateID int64
+		err = tx.QueryRow("SELECT ID FROM CalendarEvents WHERE EventName = ? AND StartTime = ? AND UID != ? AND "+notDeleted, event.EventName, start, event.UID).Scan(&duplicateID)
+		if err == nil {
+			report.Errors = append(report.Errors, fmt.Errorf("%w: %s on %s is event %d", ErrDuplicateEvent, event.EventName, start, duplicateID))
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return report, fmt.Errorf("failed to check for duplicate events: %v", err)
+		}
+
+		now := timestamp()
+		values := []any{event.EventName, start, storedEndTime(event.End, event.AllDay), event.Venue, event.URL, event.Description, event.RRule, storedExDates(event.ExDates)}
+		if found {
+			if err := j.changed(existing.ID); err != nil {
+				return report, err
+			}
+			query := "UPDATE CalendarEvents SET EventName = ?, StartTime = ?, EndTime = ?, Venue = ?, URL = ?, Description = ?, RRule = ?, ExDates = ?, UpdatedAt = ?, DeletedAt = NULL WHERE ID = ?"
+			_, err = tx.Exec(query, append(values, now, existing.ID)...)
+			report.Updated++
+		} else {
+			query := "INSERT INTO CalendarEvents (EventName, StartTime, EndTime, Venue, URL, Description, RRule, ExDates, UID, CreatedAt, UpdatedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+			var res sql.Result
+			res, err = tx.Exec(query, append(values, event.UID, now, now)...)
+			if err == nil {
+				id, _ := res.LastInsertId()
+				err = j.created(id)
+			}
+			report.Inserted++
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to import %s: %v", event.EventName, err)
+		}
+	}
+	if err := j.finish(fmt.Sprintf("imported %s, %d new and %d updated", EventCount(int64(report.Inserted+report.Updated)), report.Inserted, report.Updated)); err != nil {
+		return report, err
+	}
+	if err := tx.Commit(); err != nil {
+		return report, fmt.Errorf("failed to commit import: %v", err)
+	}
+	return report, nil
+}
+
+// reports whether importing the event would leave the stored event as it is
+func sameDetails(existing CalendarEvent, event CalendarEvent) bool {
+	return existing.EventName == event.EventName &&
+		formatStoredTime(existing.Start, existing.AllDay) == formatStoredTime(event.Start, event.AllDay) &&
+		storedEndTime(existing.End, existing.AllDay) == storedEndTime(event.End, event.AllDay) &&
+		existing.Venue == event.Venue &&
+		existing.URL == event.URL &&
+		existing.Description == event.Description &&
+		existing.RRule == event.RRule &&
+		storedExDates(existing.ExDates) == storedExDates(event.ExDates)
+}
+
+// a uid made from the name and start of an event, so an event without a uid is matched when it is imported again
+func generatedUID(event CalendarEvent) string {
+	hash := sha1.Sum([]byte(event.EventName + "|" + formatStoredTime(event.Start, event.AllDay)))
+	return hex.EncodeToString(hash[:]) + "@go_events_cli"
+}
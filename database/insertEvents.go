@@ -0,0 +1,202 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// NewEvent is an event to add to the calendar as it was entered by the user.
+type NewEvent struct {
+	EventName string `json:"name" yaml:"name"`
+	// date and optional times of the event in any format accepted by ParseEventTime
+	Date  string `json:"date" yaml:"date"`
+	Venue string `json:"venue" yaml:"venue"`
+	// repeat rule of a recurring event in any form accepted by ParseRepeat, "" for an event that happens once
+	Repeat string `json:"repeat" yaml:"repeat"`
+	// reason the input of the event could not be read, an event with a ParseErr is reported invalid rather than stopping the batch
+	ParseErr error `json:"-" yaml:"-"`
+}
+
+/*
+Validate checks the input of the event could be read, the event has a name, a date in a format accepted by ParseEventTime and a valid repeat rule if it repeats.
+Returns:
+- error: wrapping ErrInvalidEvent with the reason the event is invalid.
+*/
+func (e NewEvent) Validate() error {
+	if e.ParseErr != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEvent, e.ParseErr)
+	}
+	if strings.TrimSpace(e.EventName) == "" {
+		return fmt.Errorf("%w: missing name", ErrInvalidEvent)
+	}
+	if strings.TrimSpace(e.Date) == "" {
+		return fmt.Errorf("%w: missing date", ErrInvalidEvent)
+	}
+	if _, _, _, err := ParseEventTime(e.Date); err != nil {
+		return err
+	}
+	if strings.TrimSpace(e.Repeat) != "" {
+		_, _, err := ParseRepeat(e.Repeat)
+		return err
+	}
+	return nil
+}
+
+// AddStatus is what happened to an event when a batch of events was added.
+type AddStatus string
+
+const (
+	Inserted AddStatus = "inserted"
+	// an event with the same name and start is already in the calendar, or earlier in the batch
+	SkippedDuplicate AddStatus = "duplicate"
+	Invalid          AddStatus = "invalid"
+	// a valid event that was not added because an atomic batch was rolled back
+	RolledBack AddStatus = "rolled back"
+)
+
+// AddResult is the outcome of adding one event of a batch.
+type AddResult struct {
+	Event  NewEvent
+	Status AddStatus
+	// id of the inserted event, 0 unless Status is Inserted
+	ID int64
+	// reason the event is invalid or a duplicate
+	Err error
+}
+
+// AddReport is the outcome of adding a batch of events, with a result for every event in the order they were given.
+type AddReport struct {
+	Results    []AddResult
+	Inserted   int
+	Duplicates int
+	Invalid    int
+	// true when an atomic batch was rolled back because an event was invalid
+	RolledBack bool
+}
+
+/*
+InsertEvents adds a batch of events to the CalendarEvents table in one transaction using a prepared statement. Invalid events and duplicates of events already in the calendar are skipped and reported. In atomic mode the whole batch is rolled back if any event is invalid, so either every valid event is added or none are.
+Parameters:
+- events: []NewEvent: the events to add.
+- atomic: bool: roll back the batch if any event is invalid.
+Returns:
+- AddReport: the result of every event.
+- error: if the database could not be written, the batch is rolled back.
+*/
+func InsertEvents(db *sql.DB, events []NewEvent, atomic bool) (AddReport, error) {
+	var report AddReport
+	tx, err := db.Begin()
+	if err != nil {
+		return report, fmt.Errorf("failed to start adding events: %v", err)
+	}
+	defer tx.Rollback()
+	j, err := startJournal(tx, "add")
+	if err != nil {
+		return report, err
+	}
+	// an event with the same name and start is already in the calendar
+	insert, err := tx.Prepare("INSERT OR IGNORE INTO CalendarEvents (EventName, StartTime, EndTime, Venue, RRule, ExDates, UID, CreatedAt, UpdatedAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return report, fmt.Errorf("failed to prepare insert: %v", err)
+	}
+	defer insert.Close()
+
+	now := timestamp()
+	for _, event := range events {
+		result := AddResult{Event: event}
+		if err := event.Validate(); err != nil {
+			result.Status, result.Err = Invalid, err
+			report.add(result)
+			continue
+		}
+		start, end, allDay, _ := ParseEventTime(event.Date)
+		eventName := strings.TrimSpace(event.EventName)
+		var rrule, exDates any
+		if strings.TrimSpace(event.Repeat) != "" {
+			rule, except, _ := ParseRepeat(event.Repeat)
+			rrule, exDates = rule.RRule(allDay), storedExDates(except)
+		}
+		res, err := insert.Exec(eventName, formatStoredTime(start, allDay), storedEndTime(end, allDay), strings.TrimSpace(event.Venue), rrule, exDates, newUID(), now, now)
+		if err != nil {
+			return AddReport{}, fmt.Errorf("failed to add %s to the database, no events were added: %v", eventName, err)
+		}
+		if inserted, _ := res.RowsAffected(); inserted == 0 {
+			result.Status, result.Err = SkippedDuplicate, fmt.Errorf("%w: %s on %s", ErrDuplicateEvent, eventName, strings.TrimSpace(event.Date))
+			report.add(result)
+			continue
+		}
+		result.Status = Inserted
+		result.ID, _ = res.LastInsertId()
+		if err := j.created(result.ID); err != nil {
+			return AddReport{}, err
+		}
+		report.add(result)
+	}
+
+	if atomic && report.Invalid > 0 {
+		// nothing is committed, the deferred rollback discards the inserted events
+		report.rollBack()
+		return report, nil
+	}
+	if err := j.finish(fmt.Sprintf("added %s", EventCount(int64(report.Inserted)))); err != nil {
+		return AddReport{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return AddReport{}, fmt.Errorf("failed to commit events: %v", err)
+	}
+	return report, nil
+}
+
+// records the result of an event and counts it
+func (r *AddReport) add(result AddResult) {
+	r.Results = append(r.Results, result)
+	switch result.Status {
+	case Inserted:
+		r.Inserted++
+	case SkippedDuplicate:
+		r.Duplicates++
+	case Invalid:
+		r.Invalid++
+	}
+}
+
+// marks the inserted events of a rolled back batch as not added
+func (r *AddReport) rollBack() {
+	r.RolledBack = true
+	r.Inserted = 0
+	for i := range r.Results {
+		if r.Results[i].Status == Inserted {
+			r.Results[i].Status = RolledBack
+			r.Results[i].ID = 0
+		}
+	}
+}
+
+/*
+ParseEventList parses the legacy -add-events list of event names each followed by their date, eg "event name, 2023-11-05, event 2, 2023-11-06 19:30". Items are split on commas and a name runs until the next item that is a date, so names can contain commas and the space after a comma is optional.
+Returns:
+- []NewEvent: the events in the list.
+- error: wrapping ErrInvalidEvent if the list ends with a name that has no date or a date has no name.
+*/
+func ParseEventList(events string) ([]NewEvent, error) {
+	var parsed []NewEvent
+	var nameParts []string
+	for _, item := range strings.Split(events, ",") {
+		item = strings.TrimSpace(item)
+		if _, _, _, err := ParseEventTime(item); err != nil {
+			nameParts = append(nameParts, item)
+			continue
+		}
+		name := strings.TrimSpace(strings.Join(nameParts, ", "))
+		if name == "" {
+			return nil, fmt.Errorf("%w: date %s has no event name before it", ErrInvalidEvent, item)
+		}
+		parsed = append(parsed, NewEvent{EventName: name, Date: item})
+		nameParts = nil
+	}
+	if len(nameParts) > 0 {
+		return nil, fmt.Errorf("%w: %s has no date after it, dates are in format YYYY-MM-DD", ErrInvalidEvent, strings.Join(nameParts, ", "))
+	}
+	return parsed, nil
+}
@@ -0,0 +1,269 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNothingToUndo is returned by Undo when every change in the journal has been undone.
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// number of changes kept in the journal, older changes can no longer be undone
+const journalLimit = 50
+
+// JournalEntry is a change to the calendar recorded so it can be undone.
+type JournalEntry struct {
+	ID int64
+	// the kind of change: add, update, delete, restore or import
+	Operation string
+	// what the change did, eg "deleted 3 events"
+	Description string
+	// number of events the change touched
+	Events    int
+	CreatedAt time.Time
+	// zero until the change is undone
+	UndoneAt time.Time
+}
+
+// the stored values of an event before a change, written to JournalEvents.Before as json
+type eventSnapshot struct {
+	EventName   string
+	StartTime   string
+	EndTime     string
+	Venue       string
+	UID         string
+	URL         string
+	Description string
+	RRule       string
+	ExDates     string
+	City        string
+	Genre       string
+	Provider    string
+	DeletedAt   string
+	UpdatedAt   string
+}
+
+// records the events changed by one operation inside the operation's transaction
+type journal struct {
+	tx     *sql.Tx
+	id     int64
+	events int
+}
+
+/*
+Starts a journal entry for an operation, the events it changes are recorded with created and changed before the transaction is committed.
+Parameters:
+- tx: *sql.Tx: the transaction of the operation.
+- operation: string: the kind of change.
+Returns:
+- *journal: the entry to record the events in.
+- error: if the entry could not be written.
+*/
+func startJournal(tx *sql.Tx, operation string) (*journal, error) {
+	res, err := tx.Exec("INSERT INTO Journal (Operation, Description, CreatedAt) VALUES (?, '', ?)", operation, timestamp())
+	if err != nil {
+		return nil, fmt.Errorf("failed to write journal: %v", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return &journal{tx: tx, id: id}, nil
+}
+
+// records an event inserted by the operation, undoing the operation removes it
+func (j *journal) created(eventID int64) error {
+	_, err := j.tx.Exec("INSERT INTO JournalEvents (JournalID, EventID, Before) VALUES (?, ?, NULL)", j.id, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to write journal: %v", err)
+	}
+	j.events++
+	return nil
+}
+
+// records the stored values of an event before the operation changes it, undoing the operation puts them back
+func (j *journal) changed(eventID int64) error {
+	var s eventSnapshot
+	err := j.tx.QueryRow(`SELECT EventName, StartTime, COALESCE(EndTime, ''), COALESCE(Venue, ''), COALESCE(UID, ''), COALESCE(URL, ''), COALESCE(Description, ''), COALESCE(RRule, ''), COALESCE(ExDates, ''),
+		COALESCE(City, ''), COALESCE(Genre, ''), COALESCE(Provider, ''), COALESCE(DeletedAt, ''), UpdatedAt FROM CalendarEvents WHERE ID = ?`, eventID).
+		Scan(&s.EventName, &s.StartTime, &s.EndTime, &s.Venue, &s.UID, &s.URL, &s.Description, &s.RRule, &s.ExDates, &s.City, &s.Genre, &s.Provider, &s.DeletedAt, &s.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to read event %d for the journal: %v", eventID, err)
+	}
+	before, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if _, err := j.tx.Exec("INSERT INTO JournalEvents (JournalID, EventID, Before) VALUES (?, ?, ?)", j.id, eventID, string(before)); err != nil {
+		return fmt.Errorf("failed to write journal: %v", err)
+	}
+	j.events++
+	return nil
+}
+
+/*
+Finishes the journal entry with a description of the change, an entry that changed no events is removed. Entries older than the last journalLimit are dropped.
+*/
+func (j *journal) finish(description string) error {
+	if j.events == 0 {
+		_, err := j.tx.Exec("DELETE FROM Journal WHERE ID = ?", j.id)
+		return err
+	}
+	if _, err := j.tx.Exec("UPDATE Journal SET Description = ? WHERE ID = ?", description, j.id); err != nil {
+		return fmt.Errorf("failed to write journal: %v", err)
+	}
+	statements := []string{
+		"DELETE FROM JournalEvents WHERE JournalID IN (SELECT ID FROM Journal WHERE ID <= ?)",
+		"DELETE FROM Journal WHERE ID <= ?",
+	}
+	for _, statement := range statements {
+		if _, err := j.tx.Exec(statement, j.id-journalLimit); err != nil {
+			return fmt.Errorf("failed to prune journal: %v", err)
+		}
+	}
+	return nil
+}
+
+/*
+GetJournal returns the most recent changes to the calendar, newest first.
+Parameters:
+- limit: int: the maximum number of changes to return.
+Returns:
+- []JournalEntry: the changes, including ones that have been undone.
+- error: if the journal could not be read.
+*/
+func GetJournal(db *sql.DB, limit int) ([]JournalEntry, error) {
+	query := `SELECT Journal.ID, Operation, Description, Journal.CreatedAt, COALESCE(UndoneAt, ''), COUNT(JournalEvents.EventID)
+		FROM Journal LEFT JOIN JournalEvents ON JournalEvents.JournalID = Journal.ID
+		GROUP BY Journal.ID ORDER BY Journal.ID DESC LIMIT ?`
+	rows, err := db.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the journal: %v", err)
+	}
+	defer rows.Close()
+	var entries []JournalEntry
+	for rows.Next() {
+		var entry JournalEntry
+		var createdAt, undoneAt string
+		if err := rows.Scan(&entry.ID, &entry.Operation, &entry.Description, &createdAt, &undoneAt, &entry.Events); err != nil {
+			return nil, fmt.Errorf("failed to scan journal row: %v", err)
+		}
+		entry.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		entry.UndoneAt, _ = time.Parse(time.RFC3339, undoneAt)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+/*
+Undo reverts the most recent change to the calendar that has not been undone. Added events are removed, updated events get their previous details back and deleted events are restored from the trash. Undoing again reverts the change before that.
+Returns:
+- JournalEntry: the change that was undone.
+- error: ErrNothingToUndo if there is no change left to undo or ErrDuplicateEvent if a restored event clashes with an event added since, nothing is reverted.
+*/
+func Undo(db *sql.DB) (JournalEntry, error) {
+	var entry JournalEntry
+	tx, err := db.Begin()
+	if err != nil {
+		return entry, err
+	}
+	defer tx.Rollback()
+
+	var createdAt string
+	err = tx.QueryRow("SELECT ID, Operation, Description, CreatedAt FROM Journal WHERE UndoneAt IS NULL ORDER BY ID DESC LIMIT 1").
+		Scan(&entry.ID, &entry.Operation, &entry.Description, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entry, ErrNothingToUndo
+	}
+	if err != nil {
+		return entry, fmt.Errorf("failed to read the journal: %v", err)
+	}
+	entry.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+
+	// revert the events in the reverse of the order they were changed
+	rows, err := tx.Query("SELECT EventID, Before FROM JournalEvents WHERE JournalID = ? ORDER BY rowid DESC", entry.ID)
+	if err != nil {
+		return entry, fmt.Errorf("failed to read the journal: %v", err)
+	}
+	type change struct {
+		eventID int64
+		before  sql.NullString
+	}
+	var changes []change
+	for rows.Next() {
+		var c change
+		if err := rows.Scan(&c.eventID, &c.before); err != nil {
+			rows.Close()
+			return entry, fmt.Errorf("failed to scan journal row: %v", err)
+		}
+		changes = append(changes, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return entry, err
+	}
+
+	for _, c := range changes {
+		if !c.before.Valid {
+			if _, err := tx.Exec("DELETE FROM CalendarEvents WHERE ID = ?", c.eventID); err != nil {
+				return entry, fmt.Errorf("failed to remove event %d: %v", c.eventID, err)
+			}
+			continue
+		}
+		if err := restoreSnapshot(tx, c.eventID, c.before.String); err != nil {
+			return entry, err
+		}
+	}
+	entry.Events = len(changes)
+
+	now := timestamp()
+	if _, err := tx.Exec("UPDATE Journal SET UndoneAt = ? WHERE ID = ?", now, entry.ID); err != nil {
+		return entry, fmt.Errorf("failed to write journal: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return entry, fmt.Errorf("failed to commit undo: %v", err)
+	}
+	entry.UndoneAt, _ = time.Parse(time.RFC3339, now)
+	return entry, nil
+}
+
+// writes the stored values of an event recorded by journal.changed back to the event
+func restoreSnapshot(tx *sql.Tx, eventID int64, before string) error {
+	var s eventSnapshot
+	if err := json.Unmarshal([]byte(before), &s); err != nil {
+		return fmt.Errorf("invalid journal entry for event %d: %v", eventID, err)
+	}
+	if s.DeletedAt == "" {
+		var duplicateID int64
+		err := tx.QueryRow("SELECT ID FROM CalendarEvents WHERE EventName = ? AND StartTime = ? AND ID != ? AND "+notDeleted, s.EventName, s.StartTime, eventID).Scan(&duplicateID)
+		if err == nil {
+			return fmt.Errorf("%w: cannot restore %s on %s, it is event %d", ErrDuplicateEvent, s.EventName, s.StartTime, duplicateID)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to check for duplicate events: %v", err)
+		}
+	}
+	query := `UPDATE CalendarEvents SET EventName = ?, StartTime = ?, EndTime = NULLIF(?, ''), Venue = ?, UID = ?, URL = ?, Description = ?, RRule = ?, ExDates = NULLIF(?, ''),
+		City = ?, Genre = ?, Provider = ?, DeletedAt = NULLIF(?, ''), UpdatedAt = ? WHERE ID = ?`
+	res, err := tx.Exec(query, s.EventName, s.StartTime, s.EndTime, s.Venue, s.UID, s.URL, s.Description, s.RRule, s.ExDates, s.City, s.Genre, s.Provider, s.DeletedAt, s.UpdatedAt, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to restore event %d: %v", eventID, err)
+	}
+	if restored, _ := res.RowsAffected(); restored == 0 {
+		return fmt.Errorf("%w: %d", ErrEventNotFound, eventID)
+	}
+	return nil
+}
+
+/*
+EventCount returns the number of events followed by event or events, eg "1 event" or "3 events".
+*/
+func EventCount(n int64) string {
+	if n == 1 {
+		return "1 event"
+	}
+	return fmt.Sprintf("%d events", n)
+}
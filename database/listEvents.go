@@ -0,0 +1,152 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Order is the order ListEvents returns events in.
+type Order string
+
+const (
+	// earliest start first
+	Ascending Order = "asc"
+	// latest start first
+	Descending Order = "desc"
+)
+
+// ListFilter selects the events returned by ListEvents, zero fields do not filter.
+type ListFilter struct {
+	// events starting on or after the date
+	From time.Time
+	// events starting before the date
+	To time.Time
+	// text the event name or venue contains, case insensitive
+	Text string
+	// maximum number of events, 0 for no limit
+	Limit int
+	// Ascending when empty
+	Order Order
+	// return a repeating event once as it is stored, with the start of its first occurrence, rather than each occurrence
+	Series bool
+}
+
+/*
+ListEvents returns the events in the calendar matching the filter. The filter runs in the query against the indexed StartTime column, the stored dates sort as text so a date alone sorts before the times on that day. Repeating events are expanded into an event for each occurrence in the range, a range without an end expands them recurrenceHorizonYears ahead, unless the filter asks for the Series.
+Parameters:
+- filter: ListFilter: the events to return.
+Returns:
+- []CalendarEvent: the matching events ordered by start, the occurrences of a repeating event have its id.
+- error: if the order is unknown or the events could not be read.
+*/
+func ListEvents(db *sql.DB, filter ListFilter) ([]CalendarEvent, error) {
+	var direction string
+	switch filter.Order {
+	case Ascending, "":
+		direction = "ASC"
+	case Descending:
+		direction = "DESC"
+	default:
+		return nil, fmt.Errorf("unknown event order %s, expected %s or %s", filter.Order, Ascending, Descending)
+	}
+
+	conditions := []string{notDeleted}
+	var args []any
+	if text := strings.TrimSpace(filter.Text); text != "" {
+		// instr on the lowered values so % and _ in the text are not wildcards
+		conditions = append(conditions, "(instr(lower(EventName), ?) > 0 OR instr(lower(COALESCE(Venue, '')), ?) > 0)")
+		args = append(args, strings.ToLower(text), strings.ToLower(text))
+	}
+	if filter.Series {
+		return queryEvents(db, conditions, args, filter, direction)
+	}
+
+	// events that happen once are filtered and limited by the query
+	once := append(conditions[:len(conditions):len(conditions)], "COALESCE(RRule, '') = ''")
+	events, err := queryEvents(db, once, args, filter, direction)
+	if err != nil {
+		return nil, err
+	}
+
+	// repeating events starting before the end of the range may have occurrences in it
+	repeating := append(conditions[:len(conditions):len(conditions)], "COALESCE(RRule, '') != ''")
+	repeatingArgs := args
+	to := filter.To
+	if to.IsZero() {
+		from := filter.From
+		if from.IsZero() {
+			from = time.Now()
+		}
+		to = from.AddDate(recurrenceHorizonYears, 0, 0)
+	} else {
+		repeating = append(repeating, "StartTime < ?")
+		repeatingArgs = append(repeatingArgs, to.Format(storedDateFormat))
+	}
+	series, err := queryEvents(db, repeating, repeatingArgs, ListFilter{Series: true}, direction)
+	if err != nil {
+		return nil, err
+	}
+	if len(series) == 0 {
+		return events, nil
+	}
+	for _, event := range series {
+		events = append(events, event.Occurrences(filter.From, to)...)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if filter.Order == Descending {
+			return events[i].Start.After(events[j].Start)
+		}
+		return events[i].Start.Before(events[j].Start)
+	})
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[:filter.Limit]
+	}
+	return events, nil
+}
+
+// runs the query of ListEvents with the date range, order and limit of the filter
+func queryEvents(db *sql.DB, conditions []string, args []any, filter ListFilter, direction string) ([]CalendarEvent, error) {
+	conditions = append([]string{}, conditions...)
+	args = append([]any{}, args...)
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "StartTime >= ?")
+		args = append(args, filter.From.Format(storedDateFormat))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "StartTime < ?")
+		args = append(args, filter.To.Format(storedDateFormat))
+	}
+	query := "SELECT " + eventColumns + " FROM CalendarEvents WHERE " + strings.Join(conditions, " AND ") +
+		" ORDER BY StartTime " + direction + ", ID " + direction
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events from the database: %v", err)
+	}
+	defer rows.Close()
+	var events []CalendarEvent
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+/*
+UpcomingEvents returns the events starting on or after today in the order they start.
+Parameters:
+- now: time.Time: the current time.
+*/
+func UpcomingEvents(db *sql.DB, now time.Time) ([]CalendarEvent, error) {
+	return ListEvents(db, ListFilter{From: now})
+}
@@ -0,0 +1,228 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultCalendar is the calendar used when no calendar is chosen, stored in calendar.db like the single calendar of older versions.
+const DefaultCalendar = "calendar"
+
+// environment variables choosing the database directory and calendar when the flags are not given
+const (
+	DirEnv      = "EVENTS_CLI_DB_DIR"
+	CalendarEnv = "EVENTS_CLI_CALENDAR"
+)
+
+// ErrInvalidCalendar is returned for a calendar name that cannot be used as a file name.
+var ErrInvalidCalendar = errors.New("invalid calendar name")
+
+// calendar names are used as file names
+var calendarNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// where the calendar database of older versions was created, relative to the working directory
+const legacyDBFile = "database/calendar.db"
+
+// the directory and calendar opened by InitDB, set from the command line flags by SetLocation
+var location struct {
+	dir      string
+	calendar string
+}
+
+/*
+SetLocation chooses the directory of the calendar databases and the calendar InitDB opens. Empty values fall back to the EVENTS_CLI_DB_DIR and EVENTS_CLI_CALENDAR environment variables, then to the XDG data directory and DefaultCalendar.
+Parameters:
+- dir: string: the directory of the databases, "" for the default.
+- calendar: string: the name of the calendar, "" for the default.
+Returns:
+- error: ErrInvalidCalendar if the calendar name is not valid.
+*/
+func SetLocation(dir string, calendar string) error {
+	if calendar == "" {
+		calendar = os.Getenv(CalendarEnv)
+	}
+	if calendar == "" {
+		calendar = DefaultCalendar
+	}
+	if err := validCalendarName(calendar); err != nil {
+		return err
+	}
+	location.dir, location.calendar = dir, calendar
+	return nil
+}
+
+// checks a calendar name can be used as the name of its database file
+func validCalendarName(name string) error {
+	if !calendarNamePattern.MatchString(name) {
+		return fmt.Errorf("%w %q, use letters, numbers, - and _", ErrInvalidCalendar, name)
+	}
+	return nil
+}
+
+/*
+CurrentCalendar returns the name of the calendar InitDB opens.
+*/
+func CurrentCalendar() string {
+	if location.calendar == "" {
+		if calendar := os.Getenv(CalendarEnv); calendar != "" {
+			return calendar
+		}
+		return DefaultCalendar
+	}
+	return location.calendar
+}
+
+/*
+DataDir returns the directory of the calendar databases: the directory given to SetLocation, else EVENTS_CLI_DB_DIR, else go_events_cli in $XDG_DATA_HOME or ~/.local/share.
+Returns:
+- string: the absolute directory, it may not exist yet.
+- error: if there is no home directory to default to.
+*/
+func DataDir() (string, error) {
+	dir := location.dir
+	if dir == "" {
+		dir = os.Getenv(DirEnv)
+	}
+	if dir == "" {
+		dataHome := os.Getenv("XDG_DATA_HOME")
+		if dataHome == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", fmt.Errorf("no directory for the database, set -db-dir or %s: %v", DirEnv, err)
+			}
+			dataHome = filepath.Join(home, ".local", "share")
+		}
+		dir = filepath.Join(dataHome, "go_events_cli")
+	}
+	return filepath.Abs(dir)
+}
+
+/*
+CalendarPath returns the path of the database file of a named calendar.
+Returns:
+- string: the path in DataDir.
+- error: ErrInvalidCalendar if the name is not valid, or the error of DataDir.
+*/
+func CalendarPath(name string) (string, error) {
+	if err := validCalendarName(name); err != nil {
+		return "", err
+	}
+	dir, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".db"), nil
+}
+
+// InitDB opens the calendar chosen by SetLocation, creating it if it does not exist.
+func InitDB() (*sql.DB, error) {
+	return OpenCalendar(CurrentCalendar())
+}
+
+/*
+OpenCalendar opens the database of a named calendar, creating the directory and the database if they do not exist and migrating the schema. The default calendar is copied from database/calendar.db in the working directory, where older versions created it, the first time it is opened.
+Parameters:
+- name: string: the name of the calendar.
+Returns:
+- *sql.DB: the open database.
+- error: ErrInvalidCalendar if the name is not valid, or if the database cannot be created, opened or migrated.
+*/
+func OpenCalendar(name string) (*sql.DB, error) {
+	dbFileName, err := CalendarPath(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dbFileName), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %v", err)
+	}
+	if name == DefaultCalendar {
+		if err := copyLegacyDB(dbFileName); err != nil {
+			return nil, err
+		}
+	}
+
+	// Open the SQLite database file, it is created when it does not exist.
+	db, err := sql.Open("sqlite", dbFileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+
+	// Ping the database to check if the connection is valid.
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %v", err)
+	}
+
+	// bring the schema up to date, migrating calendar.db files created by older versions in place
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// copies the calendar of older versions to the database file the first time the default calendar is opened, the old file is left in place
+func copyLegacyDB(dbFileName string) error {
+	if _, err := os.Stat(dbFileName); !errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	legacy, err := os.Open(legacyDBFile)
+	if err != nil {
+		return nil
+	}
+	defer legacy.Close()
+	if info, err := legacy.Stat(); err != nil || info.Size() == 0 {
+		return nil
+	}
+	file, err := os.OpenFile(dbFileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to copy %s: %v", legacyDBFile, err)
+	}
+	if _, err := io.Copy(file, legacy); err != nil {
+		file.Close()
+		os.Remove(dbFileName)
+		return fmt.Errorf("failed to copy %s: %v", legacyDBFile, err)
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Copied the calendar from %s to %s\n", legacyDBFile, dbFileName)
+	return nil
+}
+
+/*
+Calendars returns the names of the calendars in DataDir.
+Returns:
+- []string: the names in alphabetical order.
+- error: if the directory cannot be read.
+*/
+func Calendars() ([]string, error) {
+	dir, err := DataDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", dir, err)
+	}
+	var names []string
+	for _, file := range files {
+		name, ok := strings.CutSuffix(file.Name(), ".db")
+		if ok && !file.IsDir() && validCalendarName(name) == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
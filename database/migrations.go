@@ -0,0 +1,261 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// a schema change applied in order when the database is opened, each migration runs once and is recorded in schema_version
+type migration struct {
+	version     int
+	description string
+	statements  []string
+}
+
+/*
+Ordered up-migrations of the calendar schema. Existing migrations must never be edited, to change the schema append a new migration with the next version.
+*/
+var migrations = []migration{
+	{
+		version:     1,
+		description: "create CalendarEvents table",
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS CalendarEvents (
+				EventName TEXT,
+				Date TEXT
+			)`,
+		},
+	},
+	{
+		version:     2,
+		description: "add ids, start/end times and timestamps to CalendarEvents",
+		statements: []string{
+			`CREATE TABLE CalendarEventsV2 (
+				ID INTEGER PRIMARY KEY,
+				EventName TEXT NOT NULL,
+				StartTime TEXT NOT NULL,
+				EndTime TEXT,
+				CreatedAt TEXT NOT NULL,
+				UpdatedAt TEXT NOT NULL
+			)`,
+			// copy the existing events dropping exact duplicates, which the unique index no longer allows
+			`INSERT INTO CalendarEventsV2 (EventName, StartTime, CreatedAt, UpdatedAt)
+				SELECT COALESCE(EventName, ''), COALESCE(Date, ''), strftime('%Y-%m-%dT%H:%M:%SZ', 'now'), strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+				FROM CalendarEvents
+				GROUP BY EventName, Date
+				ORDER BY MIN(rowid)`,
+			`DROP TABLE CalendarEvents`,
+			`ALTER TABLE CalendarEventsV2 RENAME TO CalendarEvents`,
+			`CREATE UNIQUE INDEX CalendarEventsNameStart ON CalendarEvents (EventName, StartTime)`,
+		},
+	},
+	{
+		version:     3,
+		description: "add venue to CalendarEvents",
+		statements: []string{
+			`ALTER TABLE CalendarEvents ADD COLUMN Venue TEXT`,
+		},
+	},
+	{
+		version:     4,
+		description: "add iCalendar uid, url, description and recurrence rule to CalendarEvents",
+		statements: []string{
+			`ALTER TABLE CalendarEvents ADD COLUMN UID TEXT`,
+			`ALTER TABLE CalendarEvents ADD COLUMN URL TEXT`,
+			`ALTER TABLE CalendarEvents ADD COLUMN Description TEXT`,
+			`ALTER TABLE CalendarEvents ADD COLUMN RRule TEXT`,
+			// give existing events a uid so they keep the same uid every time they are exported
+			`UPDATE CalendarEvents SET UID = lower(hex(randomblob(16))) || '@go_events_cli' WHERE UID IS NULL`,
+			`CREATE UNIQUE INDEX CalendarEventsUID ON CalendarEvents (UID)`,
+		},
+	},
+	{
+		version:     5,
+		description: "create Subscriptions and SubscriptionEvents tables",
+		statements: []string{
+			`CREATE TABLE Subscriptions (
+				ID INTEGER PRIMARY KEY,
+				Name TEXT NOT NULL UNIQUE,
+				Source TEXT NOT NULL,
+				RefreshedAt TEXT,
+				LastError TEXT,
+				CreatedAt TEXT NOT NULL
+			)`,
+			`CREATE TABLE SubscriptionEvents (
+				SubscriptionID INTEGER NOT NULL REFERENCES Subscriptions (ID),
+				UID TEXT,
+				EventName TEXT NOT NULL,
+				StartTime TEXT NOT NULL,
+				EndTime TEXT
+			)`,
+			`CREATE INDEX SubscriptionEventsSubscription ON SubscriptionEvents (SubscriptionID)`,
+		},
+	},
+	{
+		version:     6,
+		description: "create SearchResults cache and add search details to CalendarEvents",
+		statements: []string{
+			`CREATE TABLE SearchResults (
+				Ref TEXT PRIMARY KEY,
+				EventName TEXT NOT NULL,
+				StartTime TEXT NOT NULL,
+				EndTime TEXT,
+				City TEXT,
+				Genre TEXT,
+				Provider TEXT,
+				Tickets TEXT,
+				SearchedAt TEXT NOT NULL
+			)`,
+			`ALTER TABLE CalendarEvents ADD COLUMN City TEXT`,
+			`ALTER TABLE CalendarEvents ADD COLUMN Genre TEXT`,
+			`ALTER TABLE CalendarEvents ADD COLUMN Provider TEXT`,
+		},
+	},
+	{
+		version:     7,
+		description: "add the trash and the Journal of calendar changes",
+		statements: []string{
+			`ALTER TABLE CalendarEvents ADD COLUMN DeletedAt TEXT`,
+			// an event in the trash no longer stops the same event being added again
+			`DROP INDEX CalendarEventsNameStart`,
+			`CREATE UNIQUE INDEX CalendarEventsNameStart ON CalendarEvents (EventName, StartTime) WHERE DeletedAt IS NULL`,
+			`CREATE TABLE Journal (
+				ID INTEGER PRIMARY KEY,
+				Operation TEXT NOT NULL,
+				Description TEXT NOT NULL,
+				CreatedAt TEXT NOT NULL,
+				UndoneAt TEXT
+			)`,
+			`CREATE TABLE JournalEvents (
+				JournalID INTEGER NOT NULL REFERENCES Journal (ID),
+				EventID INTEGER NOT NULL,
+				Before TEXT
+			)`,
+			`CREATE INDEX JournalEventsJournal ON JournalEvents (JournalID)`,
+		},
+	},
+	{
+		version:     8,
+		description: "index CalendarEvents by start for listing date ranges",
+		statements: []string{
+			`CREATE INDEX CalendarEventsStartTime ON CalendarEvents (StartTime) WHERE DeletedAt IS NULL`,
+		},
+	},
+	{
+		version:     9,
+		description: "add the except dates of repeating events to CalendarEvents",
+		statements: []string{
+			`ALTER TABLE CalendarEvents ADD COLUMN ExDates TEXT`,
+		},
+	},
+	{
+		version:     10,
+		description: "add the SavedSearches table of named search parameters",
+		statements: []string{
+			`CREATE TABLE SavedSearches (
+				ID INTEGER PRIMARY KEY,
+				Name TEXT NOT NULL UNIQUE,
+				Cities TEXT NOT NULL,
+				Genres TEXT NOT NULL,
+				DateFrom TEXT NOT NULL,
+				DateTo TEXT NOT NULL,
+				Providers TEXT NOT NULL,
+				CreatedAt TEXT NOT NULL,
+				LastRunAt TEXT
+			)`,
+		},
+	},
+	{
+		version:     11,
+		description: "add the SearchSnapshots table of the results of the last run of each saved search",
+		statements: []string{
+			`ALTER TABLE SavedSearches ADD COLUMN SnapshotAt TEXT`,
+			`CREATE TABLE SearchSnapshots (
+				SavedSearchID INTEGER NOT NULL REFERENCES SavedSearches (ID),
+				Fingerprint TEXT NOT NULL,
+				EventName TEXT NOT NULL,
+				EventDate TEXT NOT NULL,
+				StartTime TEXT,
+				EndTime TEXT,
+				City TEXT,
+				Genre TEXT,
+				Providers TEXT,
+				Tickets TEXT
+			)`,
+			`CREATE INDEX SearchSnapshotsFingerprint ON SearchSnapshots (SavedSearchID, Fingerprint)`,
+		},
+	},
+	{
+		version:     12,
+		description: "add the repeat rule and except dates of repeating events to SubscriptionEvents",
+		statements: []string{
+			`ALTER TABLE SubscriptionEvents ADD COLUMN RRule TEXT`,
+			`ALTER TABLE SubscriptionEvents ADD COLUMN ExDates TEXT`,
+		},
+	},
+	{
+		version:     13,
+		description: "add the venue to SearchSnapshots",
+		statements: []string{
+			`ALTER TABLE SearchSnapshots ADD COLUMN Venue TEXT`,
+			// the kept results have no venue so their events would not match the events found now, the next run of each saved search is a first run
+			`DELETE FROM SearchSnapshots`,
+			`UPDATE SavedSearches SET SnapshotAt = NULL`,
+		},
+	},
+}
+
+/*
+Applies every migration newer than the version recorded in the schema_version table, each in its own transaction so a failed migration leaves the database at the previous version.
+Returns:
+- error: if reading the schema version or applying a migration fails.
+*/
+func migrate(db *sql.DB) error {
+	// table recording each applied migration
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_version table: %v", err)
+	}
+
+	var currentVersion int
+	err = db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&currentVersion)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %v", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= currentVersion {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("failed to apply migration %d (%s): %v", m.version, m.description, err)
+		}
+	}
+	return nil
+}
+
+// runs the statements of a migration and records its version in one transaction
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range m.statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)",
+		m.version, m.description, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
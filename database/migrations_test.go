@@ -0,0 +1,88 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMigrateLegacyCalendar(t *testing.T) {
+	dir := t.TempDir()
+	// a calendar.db as created by versions before the migrations, with a duplicate event
+	legacy, err := sql.Open("sqlite", filepath.Join(dir, "legacy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	statements := []string{
+		`CREATE TABLE CalendarEvents (EventName TEXT, Date TEXT)`,
+		`INSERT INTO CalendarEvents (EventName, Date) VALUES ('Gig', '2026-11-05'), ('Festival', '2026-12-01'), ('Gig', '2026-11-05'), ('Play', '2026-11-20')`,
+	}
+	for _, statement := range statements {
+		if _, err := legacy.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	legacy.Close()
+
+	if err := SetLocation(dir, "legacy"); err != nil {
+		t.Fatal(err)
+	}
+	db, err := InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var version int
+	if err := db.QueryRow("SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if latest := migrations[len(migrations)-1].version; version != latest {
+		t.Errorf("schema version = %d, want %d", version, latest)
+	}
+
+	events, err := ListEvents(db, ListFilter{Series: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		name  string
+		start string
+	}{
+		{"Gig", "2026-11-05"},
+		{"Play", "2026-11-20"},
+		{"Festival", "2026-12-01"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events after the upgrade, want %d: %+v", len(events), len(want), events)
+	}
+	uids := make(map[string]bool)
+	for i, event := range events {
+		if event.EventName != want[i].name || event.Start.Format(time.DateOnly) != want[i].start || !event.AllDay {
+			t.Errorf("event %d = %s on %s all day %v, want %s on %s all day", i, event.EventName, event.Start, event.AllDay, want[i].name, want[i].start)
+		}
+		if event.UID == "" || uids[event.UID] {
+			t.Errorf("event %s has uid %q, want a unique uid", event.EventName, event.UID)
+		}
+		uids[event.UID] = true
+	}
+
+	// opening the upgraded calendar again applies no migration and keeps the events
+	db.Close()
+	db, err = InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var applied int
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_version").Scan(&applied); err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("%d migrations recorded after reopening, want %d", applied, len(migrations))
+	}
+	if events, err := ListEvents(db, ListFilter{Series: true}); err != nil || len(events) != len(want) {
+		t.Errorf("got %d events after reopening, %v, want %d", len(events), err, len(want))
+	}
+}
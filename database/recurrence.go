@@ -0,0 +1,438 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// frequencies of a Recurrence
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+	Yearly  = "YEARLY"
+)
+
+// years ahead a repeating event without an end is expanded when the listed range has no end
+const recurrenceHorizonYears = 1
+
+// limit on the periods expanded for one event, stops a rule that never matches a date from looping forever
+const maxRecurrencePeriods = 100000
+
+// the two letter RFC 5545 weekday names in time.Weekday order
+var weekdayNames = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// WeekdayNum is a BYDAY weekday, Nth is the occurrence of the weekday in the month for monthly rules, eg 1 for the first or -1 for the last, 0 for every one.
+type WeekdayNum struct {
+	Nth     int
+	Weekday time.Weekday
+}
+
+// Recurrence is a repeat rule of a calendar event, the subset of an RFC 5545 RRULE the calendar expands.
+type Recurrence struct {
+	// one of Daily, Weekly, Monthly or Yearly
+	Freq string
+	// repeats every Interval days, weeks, months or years
+	Interval int
+	// weekdays the event repeats on, the weekday of the start when empty
+	ByDay []WeekdayNum
+	// days of the month of a monthly rule, negative days count from the end of the month
+	ByMonthDay []int
+	// last date the event can repeat on, zero for no limit
+	Until time.Time
+	// number of occurrences including the first, 0 for no limit
+	Count int
+}
+
+/*
+ParseRepeat parses a repeat rule, either the short form used by calendar add, eg "weekly;byday=TU;until=2027-06-01;except=2026-12-22", or an RFC 5545 RRULE such as "FREQ=WEEKLY;BYDAY=TU;UNTIL=20270601".
+Parts seperated by semicolons:
+- daily, weekly, monthly or yearly, or freq=...: how often the event repeats.
+- interval=n: repeat every n days, weeks, months or years.
+- byday=TU,TH: the weekdays, monthly rules can give the occurrence in the month, eg 1MO or -1FR.
+- bymonthday=1,15: days of the month, -1 is the last day.
+- until=YYYY-MM-DD or count=n: when the repeats stop.
+- except=YYYY-MM-DD,...: dates the event does not happen on.
+Returns:
+- Recurrence: the rule.
+- []time.Time: the dates given by except.
+- error: wrapping ErrInvalidEvent if the rule is not valid.
+*/
+func ParseRepeat(value string) (Recurrence, []time.Time, error) {
+	rule := Recurrence{Interval: 1}
+	var except []time.Time
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	for i, part := range strings.Split(value, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, val, hasValue := strings.Cut(part, "=")
+		key, val = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(val)
+		if !hasValue {
+			// the short form starts with the frequency
+			if i != 0 {
+				return rule, nil, fmt.Errorf("%w: invalid repeat %q, expected key=value", ErrInvalidEvent, part)
+			}
+			key, val = "freq", key
+		}
+		var err error
+		switch key {
+		case "freq":
+			rule.Freq = strings.ToUpper(val)
+			if rule.Freq != Daily && rule.Freq != Weekly && rule.Freq != Monthly && rule.Freq != Yearly {
+				err = fmt.Errorf("unknown frequency %s, expected daily, weekly, monthly or yearly", val)
+			}
+		case "interval":
+			rule.Interval, err = strconv.Atoi(val)
+			if err == nil && rule.Interval < 1 {
+				err = fmt.Errorf("interval must be at least 1")
+			}
+		case "count":
+			rule.Count, err = strconv.Atoi(val)
+			if err == nil && rule.Count < 1 {
+				err = fmt.Errorf("count must be at least 1")
+			}
+		case "until":
+			rule.Until, err = parseRuleDate(val)
+		case "byday":
+			rule.ByDay, err = parseByDay(val)
+		case "bymonthday":
+			rule.ByMonthDay, err = parseByMonthDay(val)
+		case "except", "exdate":
+			for _, date := range strings.Split(val, ",") {
+				exceptDate, dateErr := parseRuleDate(date)
+				if dateErr != nil {
+					err = dateErr
+					break
+				}
+				except = append(except, exceptDate)
+			}
+		case "wkst":
+			// weeks always start on monday
+		default:
+			err = fmt.Errorf("%s is not supported", strings.ToUpper(key))
+		}
+		if err != nil {
+			return rule, nil, fmt.Errorf("%w: invalid repeat %s: %v", ErrInvalidEvent, part, err)
+		}
+	}
+	if rule.Freq == "" {
+		return rule, nil, fmt.Errorf("%w: repeat %q has no frequency, expected daily, weekly, monthly or yearly", ErrInvalidEvent, value)
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return rule, nil, fmt.Errorf("%w: repeat can have until or count, not both", ErrInvalidEvent)
+	}
+	if len(rule.ByMonthDay) > 0 && rule.Freq != Monthly {
+		return rule, nil, fmt.Errorf("%w: bymonthday is only supported by monthly repeats", ErrInvalidEvent)
+	}
+	if len(rule.ByDay) > 0 && rule.Freq == Yearly {
+		return rule, nil, fmt.Errorf("%w: byday is not supported by yearly repeats", ErrInvalidEvent)
+	}
+	return rule, except, nil
+}
+
+// parses an until or except date, YYYY-MM-DD or the RFC 5545 DATE or DATE-TIME forms
+func parseRuleDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	if len(value) >= 8 {
+		if t, err := time.Parse("20060102", value[:8]); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %s, expected YYYY-MM-DD", value)
+}
+
+// parses a byday list such as TU,TH or 1MO,-1FR
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	for _, day := range strings.Split(value, ",") {
+		day = strings.ToUpper(strings.TrimSpace(day))
+		if len(day) < 2 {
+			return nil, fmt.Errorf("invalid weekday %s", day)
+		}
+		var weekdayNum WeekdayNum
+		if nth := day[:len(day)-2]; nth != "" {
+			n, err := strconv.Atoi(nth)
+			if err != nil || n == 0 || n < -5 || n > 5 {
+				return nil, fmt.Errorf("invalid weekday %s", day)
+			}
+			weekdayNum.Nth = n
+		}
+		found := false
+		for i, name := range weekdayNames {
+			if day[len(day)-2:] == name {
+				weekdayNum.Weekday, found = time.Weekday(i), true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid weekday %s, expected MO, TU, WE, TH, FR, SA or SU", day)
+		}
+		days = append(days, weekdayNum)
+	}
+	return days, nil
+}
+
+// parses a bymonthday list such as 1,15,-1
+func parseByMonthDay(value string) ([]int, error) {
+	var days []int
+	for _, day := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(day))
+		if err != nil || n == 0 || n < -31 || n > 31 {
+			return nil, fmt.Errorf("invalid day of the month %s", day)
+		}
+		days = append(days, n)
+	}
+	return days, nil
+}
+
+/*
+RRule returns the rule in RFC 5545 form without the RRULE: prefix, the form it is stored and exported in. The until of an event with a start time is written as a date-time at the end of the day as RFC 5545 requires.
+*/
+func (r Recurrence) RRule(allDay bool) string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		var days []string
+		for _, day := range r.ByDay {
+			name := weekdayNames[day.Weekday]
+			if day.Nth != 0 {
+				name = strconv.Itoa(day.Nth) + name
+			}
+			days = append(days, name)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		var days []string
+		for _, day := range r.ByMonthDay {
+			days = append(days, strconv.Itoa(day))
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if !r.Until.IsZero() {
+		until := r.Until.Format("20060102")
+		if !allDay {
+			until += "T235959"
+		}
+		parts = append(parts, "UNTIL="+until)
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	return strings.Join(parts, ";")
+}
+
+/*
+Lists the starts of the occurrences of an event starting at start, the first occurrence is the start itself. Occurrences are counted towards Count before the except dates are removed, as in RFC 5545.
+Parameters:
+- start: time.Time: the start of the first occurrence.
+- except: []time.Time: dates without an occurrence.
+- from: string: the first date to list occurrences on, in the stored date format.
+- to: string: the date to stop before, in the stored date format.
+Returns:
+- []time.Time: the starts of the occurrences on the dates from from up to to, in order.
+*/
+func (r Recurrence) between(start time.Time, except []time.Time, from string, to string) []time.Time {
+	excluded := map[string]bool{}
+	for _, date := range except {
+		excluded[date.Format(storedDateFormat)] = true
+	}
+	until := ""
+	if !r.Until.IsZero() {
+		until = r.Until.Format(storedDateFormat)
+	}
+	interval := max(r.Interval, 1)
+
+	var starts []time.Time
+	n := 0
+	for period := 0; period < maxRecurrencePeriods; period++ {
+		candidates, periodStart := r.period(start, period*interval)
+		if periodStart.Format(storedDateFormat) >= to || (until != "" && periodStart.Format(storedDateFormat) > until) {
+			break
+		}
+		for _, candidate := range candidates {
+			if candidate.Before(start) {
+				continue
+			}
+			date := candidate.Format(storedDateFormat)
+			n++
+			if (r.Count > 0 && n > r.Count) || (until != "" && date > until) || date >= to {
+				return starts
+			}
+			if !excluded[date] && date >= from {
+				starts = append(starts, candidate)
+			}
+		}
+	}
+	return starts
+}
+
+/*
+Lists the candidate starts in one period of the rule, a day, week, month or year, at the time of day of start.
+Parameters:
+- start: time.Time: the start of the first occurrence.
+- offset: int: the number of periods after the period of start.
+Returns:
+- []time.Time: the candidates in order, earlier than start for the first period.
+- time.Time: the first day of the period.
+*/
+func (r Recurrence) period(start time.Time, offset int) ([]time.Time, time.Time) {
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), 0, 0, time.UTC)
+	}
+	var candidates []time.Time
+	var periodStart time.Time
+	switch r.Freq {
+	case Daily:
+		periodStart = start.AddDate(0, 0, offset)
+		if len(r.ByDay) == 0 || r.hasWeekday(periodStart.Weekday()) {
+			candidates = append(candidates, periodStart)
+		}
+	case Weekly:
+		// weeks start on monday
+		monday := start.AddDate(0, 0, -((int(start.Weekday())+6)%7)+offset*7)
+		periodStart = at(monday.Year(), monday.Month(), monday.Day())
+		for day := 0; day < 7; day++ {
+			date := periodStart.AddDate(0, 0, day)
+			if (len(r.ByDay) == 0 && date.Weekday() == start.Weekday()) || r.hasWeekday(date.Weekday()) {
+				candidates = append(candidates, date)
+			}
+		}
+	case Monthly:
+		periodStart = at(start.Year(), start.Month()+time.Month(offset), 1)
+		daysInMonth := periodStart.AddDate(0, 1, -1).Day()
+		days := map[int]bool{}
+		for _, day := range r.ByMonthDay {
+			if day < 0 {
+				day = daysInMonth + day + 1
+			}
+			days[day] = true
+		}
+		for _, weekday := range r.ByDay {
+			for day := 1; day <= daysInMonth; day++ {
+				if periodStart.AddDate(0, 0, day-1).Weekday() != weekday.Weekday {
+					continue
+				}
+				nth, fromEnd := (day-1)/7+1, -((daysInMonth-day)/7 + 1)
+				if weekday.Nth == 0 || weekday.Nth == nth || weekday.Nth == fromEnd {
+					days[day] = true
+				}
+			}
+		}
+		if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+			days[start.Day()] = true
+		}
+		for day := range days {
+			// a month without the day, eg the 31st, has no occurrence
+			if day >= 1 && day <= daysInMonth {
+				candidates = append(candidates, periodStart.AddDate(0, 0, day-1))
+			}
+		}
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+	case Yearly:
+		periodStart = at(start.Year()+offset, time.January, 1)
+		date := at(start.Year()+offset, start.Month(), start.Day())
+		// the 29th of february only repeats in leap years
+		if date.Month() == start.Month() {
+			candidates = append(candidates, date)
+		}
+	}
+	return candidates, periodStart
+}
+
+// reports whether the weekday is one of the byday weekdays
+func (r Recurrence) hasWeekday(weekday time.Weekday) bool {
+	for _, day := range r.ByDay {
+		if day.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Repeats reports whether the event has a valid repeat rule, events with a rule that cannot be expanded are treated as happening once.
+*/
+func (e CalendarEvent) Repeats() bool {
+	if e.RRule == "" {
+		return false
+	}
+	_, _, err := ParseRepeat(e.RRule)
+	return err == nil
+}
+
+/*
+Occurrences returns the occurrences of the event starting on the dates from from up to to, each a copy of the event with the start, end and date of the occurrence. An event that does not repeat is its only occurrence.
+Parameters:
+- from: time.Time: the first date, zero for no limit.
+- to: time.Time: the date to stop before.
+*/
+func (e CalendarEvent) Occurrences(from time.Time, to time.Time) []CalendarEvent {
+	fromDate, toDate := "", to.Format(storedDateFormat)
+	if !from.IsZero() {
+		fromDate = from.Format(storedDateFormat)
+	}
+	rule, _, err := ParseRepeat(e.RRule)
+	if e.RRule == "" || err != nil {
+		if e.Date >= fromDate && e.Date < toDate {
+			return []CalendarEvent{e}
+		}
+		return nil
+	}
+	var occurrences []CalendarEvent
+	for _, start := range rule.between(e.Start, e.ExDates, fromDate, toDate) {
+		occurrence := e
+		occurrence.Start = start
+		if !e.End.IsZero() {
+			occurrence.End = start.Add(e.End.Sub(e.Start))
+		}
+		occurrence.Date = start.Format(time.DateOnly)
+		occurrences = append(occurrences, occurrence)
+	}
+	return occurrences
+}
+
+// reports whether the last occurrence of the event starts before the date, a repeating event without an until or count never ends
+func (e CalendarEvent) endedBefore(date time.Time) bool {
+	rule, _, err := ParseRepeat(e.RRule)
+	if e.RRule == "" || err != nil {
+		return e.Date < date.Format(storedDateFormat)
+	}
+	if rule.Until.IsZero() && rule.Count == 0 {
+		return false
+	}
+	// any occurrence on or after the date means the event has not ended
+	return len(rule.between(e.Start, e.ExDates, date.Format(storedDateFormat), "9999-12-31")) == 0
+}
+
+// formats the except dates of a repeating event for the ExDates column, NULL if there are none
+func storedExDates(dates []time.Time) any {
+	if len(dates) == 0 {
+		return nil
+	}
+	var formatted []string
+	for _, date := range dates {
+		formatted = append(formatted, date.Format(storedDateFormat))
+	}
+	return strings.Join(formatted, ",")
+}
+
+// parses the ExDates column
+func parseExDates(value string) []time.Time {
+	var dates []time.Time
+	for _, date := range strings.Split(value, ",") {
+		if t, err := time.Parse(storedDateFormat, strings.TrimSpace(date)); err == nil {
+			dates = append(dates, t)
+		}
+	}
+	return dates
+}
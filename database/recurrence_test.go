@@ -0,0 +1,205 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRepeat(t *testing.T) {
+	tests := []struct {
+		name   string
+		repeat string
+		// the rule in stored RRULE form for a timed event
+		rrule  string
+		except []string
+		// text the error must contain, "" if the repeat is valid
+		err string
+	}{
+		{name: "short form", repeat: "weekly", rrule: "FREQ=WEEKLY"},
+		{name: "short form with parts", repeat: "weekly;byday=tu,th;until=2027-06-01", rrule: "FREQ=WEEKLY;BYDAY=TU,TH;UNTIL=20270601T235959"},
+		{name: "rrule", repeat: "RRULE:FREQ=MONTHLY;INTERVAL=2;BYDAY=1SA,-1FR;COUNT=6", rrule: "FREQ=MONTHLY;INTERVAL=2;BYDAY=1SA,-1FR;COUNT=6"},
+		{name: "rrule date-time until", repeat: "FREQ=DAILY;UNTIL=20261231T235959Z;WKST=MO", rrule: "FREQ=DAILY;UNTIL=20261231T235959"},
+		{name: "bymonthday", repeat: "monthly;bymonthday=1,-1", rrule: "FREQ=MONTHLY;BYMONTHDAY=1,-1"},
+		{name: "except dates", repeat: "weekly;except=2026-10-27,20261103", rrule: "FREQ=WEEKLY", except: []string{"2026-10-27", "2026-11-03"}},
+		{name: "no frequency", repeat: "byday=TU", err: "no frequency"},
+		{name: "unknown frequency", repeat: "hourly", err: "unknown frequency"},
+		{name: "frequency not first", repeat: "byday=TU;weekly", err: "expected key=value"},
+		{name: "zero interval", repeat: "daily;interval=0", err: "interval must be at least 1"},
+		{name: "zero count", repeat: "daily;count=0", err: "count must be at least 1"},
+		{name: "until and count", repeat: "daily;until=2027-01-01;count=3", err: "until or count"},
+		{name: "invalid until", repeat: "daily;until=soon", err: "invalid date"},
+		{name: "invalid weekday", repeat: "weekly;byday=XX", err: "invalid weekday"},
+		{name: "weekday occurrence out of range", repeat: "monthly;byday=6MO", err: "invalid weekday"},
+		{name: "invalid month day", repeat: "monthly;bymonthday=32", err: "invalid day of the month"},
+		{name: "bymonthday not monthly", repeat: "weekly;bymonthday=1", err: "only supported by monthly"},
+		{name: "byday yearly", repeat: "yearly;byday=MO", err: "not supported by yearly"},
+		{name: "unsupported part", repeat: "FREQ=DAILY;BYHOUR=9", err: "BYHOUR is not supported"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, except, err := ParseRepeat(test.repeat)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("ParseRepeat(%q) error = %v, want one containing %q", test.repeat, err, test.err)
+				}
+				if !errors.Is(err, ErrInvalidEvent) {
+					t.Errorf("error %v does not wrap ErrInvalidEvent", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRepeat(%q) error = %v", test.repeat, err)
+			}
+			if got := rule.RRule(false); got != test.rrule {
+				t.Errorf("RRule = %s, want %s", got, test.rrule)
+			}
+			var gotExcept []string
+			for _, date := range except {
+				gotExcept = append(gotExcept, date.Format(time.DateOnly))
+			}
+			if strings.Join(gotExcept, ",") != strings.Join(test.except, ",") {
+				t.Errorf("except = %v, want %v", gotExcept, test.except)
+			}
+		})
+	}
+}
+
+func TestRecurrenceBetween(t *testing.T) {
+	tests := []struct {
+		name   string
+		start  string
+		repeat string
+		from   string
+		to     string
+		want   []string
+	}{
+		{
+			name:  "weekly on two days with count",
+			start: "2026-10-20 19:00", repeat: "weekly;byday=TU,TH;count=5",
+			to:   "2027-01-01",
+			want: []string{"2026-10-20 19:00", "2026-10-22 19:00", "2026-10-27 19:00", "2026-10-29 19:00", "2026-11-03 19:00"},
+		},
+		{
+			name:  "weekly starting on a day not in byday",
+			start: "2026-10-21 19:00", repeat: "weekly;byday=MO;count=2",
+			to:   "2027-01-01",
+			want: []string{"2026-10-26 19:00", "2026-11-02 19:00"},
+		},
+		{
+			name:  "daily every other day until",
+			start: "2026-10-20 08:30", repeat: "daily;interval=2;until=2026-10-26",
+			to:   "2027-01-01",
+			want: []string{"2026-10-20 08:30", "2026-10-22 08:30", "2026-10-24 08:30", "2026-10-26 08:30"},
+		},
+		{
+			name:  "monthly on the 31st skips short months",
+			start: "2026-01-31 00:00", repeat: "monthly",
+			to:   "2026-07-01",
+			want: []string{"2026-01-31 00:00", "2026-03-31 00:00", "2026-05-31 00:00"},
+		},
+		{
+			name:  "monthly on the last day",
+			start: "2026-01-31 00:00", repeat: "monthly;bymonthday=-1;count=4",
+			to:   "2027-01-01",
+			want: []string{"2026-01-31 00:00", "2026-02-28 00:00", "2026-03-31 00:00", "2026-04-30 00:00"},
+		},
+		{
+			name:  "monthly on the first saturday",
+			start: "2026-10-03 20:00", repeat: "monthly;byday=1SA;count=3",
+			to:   "2027-01-01",
+			want: []string{"2026-10-03 20:00", "2026-11-07 20:00", "2026-12-05 20:00"},
+		},
+		{
+			name:  "monthly on the last friday",
+			start: "2026-10-30 18:00", repeat: "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			to:   "2027-01-01",
+			want: []string{"2026-10-30 18:00", "2026-11-27 18:00", "2026-12-25 18:00"},
+		},
+		{
+			name:  "yearly on the 29th of february",
+			start: "2024-02-29 00:00", repeat: "yearly",
+			to:   "2029-01-01",
+			want: []string{"2024-02-29 00:00", "2028-02-29 00:00"},
+		},
+		{
+			name:  "except dates count towards count",
+			start: "2026-10-20 10:00", repeat: "weekly;count=4;except=2026-10-27",
+			to:   "2027-01-01",
+			want: []string{"2026-10-20 10:00", "2026-11-03 10:00", "2026-11-10 10:00"},
+		},
+		{
+			name:  "range from the middle of a series",
+			start: "2026-10-20 10:00", repeat: "weekly",
+			from: "2026-11-01", to: "2026-11-15",
+			want: []string{"2026-11-03 10:00", "2026-11-10 10:00"},
+		},
+		{
+			name:  "range after the series ends",
+			start: "2026-10-20 10:00", repeat: "weekly;until=2026-10-31",
+			from: "2026-11-01", to: "2027-01-01",
+			want: nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start, err := time.Parse("2006-01-02 15:04", test.start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rule, except, err := ParseRepeat(test.repeat)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, occurrence := range rule.between(start, except, test.from, test.to) {
+				got = append(got, occurrence.Format("2006-01-02 15:04"))
+			}
+			if strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("occurrences = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRecurrencePeriod(t *testing.T) {
+	start := time.Date(2026, 10, 22, 19, 0, 0, 0, time.UTC) // a thursday
+	tests := []struct {
+		name        string
+		repeat      string
+		offset      int
+		periodStart string
+		candidates  []string
+	}{
+		{name: "weekly period starts on monday", repeat: "weekly;byday=MO,TH", offset: 0, periodStart: "2026-10-19 19:00",
+			candidates: []string{"2026-10-19 19:00", "2026-10-22 19:00"}},
+		{name: "weekly next period", repeat: "weekly", offset: 1, periodStart: "2026-10-26 19:00",
+			candidates: []string{"2026-10-29 19:00"}},
+		{name: "monthly month without the day", repeat: "monthly;bymonthday=30,31", offset: 4, periodStart: "2027-02-01 19:00",
+			candidates: nil},
+		{name: "monthly across the year", repeat: "monthly;bymonthday=-1", offset: 3, periodStart: "2027-01-01 19:00",
+			candidates: []string{"2027-01-31 19:00"}},
+		{name: "daily filtered by weekday", repeat: "daily;byday=MO,TU,WE,TH,FR", offset: 2, periodStart: "2026-10-24 19:00",
+			candidates: nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, _, err := ParseRepeat(test.repeat)
+			if err != nil {
+				t.Fatal(err)
+			}
+			candidates, periodStart := rule.period(start, test.offset)
+			if got := periodStart.Format("2006-01-02 15:04"); got != test.periodStart {
+				t.Errorf("period start = %s, want %s", got, test.periodStart)
+			}
+			var got []string
+			for _, candidate := range candidates {
+				got = append(got, candidate.Format("2006-01-02 15:04"))
+			}
+			if strings.Join(got, ",") != strings.Join(test.candidates, ",") {
+				t.Errorf("candidates = %v, want %v", got, test.candidates)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrSavedSearchNotFound is returned when there is no saved search with a name.
+var ErrSavedSearchNotFound = errors.New("no saved search named")
+
+// SavedSearch is a named set of search parameters that can be run again. The dates are kept as they were given so relative dates such as +30d are resolved when the search is run.
+type SavedSearch struct {
+	ID        int64
+	Name      string
+	Cities    string
+	Genres    string
+	DateFrom  string
+	DateTo    string
+	Providers []string
+	CreatedAt time.Time
+	// when the search was last run, zero if it never has been
+	LastRunAt time.Time
+	// when the results of the last run were kept to compare the next run against, zero if they have not been
+	SnapshotAt time.Time
+}
+
+/*
+SaveSearch stores a saved search, replacing the parameters of a saved search with the same name. The results kept from the runs of a replaced search are cleared as they were found with the old parameters.
+Parameters:
+- search: SavedSearch: the search to save, the ID and times are ignored.
+Returns:
+- bool: true if a saved search with the name was replaced.
+- error: ErrInvalidEvent if the name is empty or the search could not be written.
+*/
+func SaveSearch(db *sql.DB, search SavedSearch) (bool, error) {
+	name := strings.TrimSpace(search.Name)
+	if name == "" {
+		return false, fmt.Errorf("%w: a saved search needs a name", ErrInvalidEvent)
+	}
+	_, err := GetSavedSearch(db, name)
+	replaced := err == nil
+	if err != nil && !errors.Is(err, ErrSavedSearchNotFound) {
+		return false, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	// the creation time and last run are kept when the parameters are replaced
+	_, err = tx.Exec(`INSERT INTO SavedSearches (Name, Cities, Genres, DateFrom, DateTo, Providers, CreatedAt) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (Name) DO UPDATE SET Cities = excluded.Cities, Genres = excluded.Genres, DateFrom = excluded.DateFrom, DateTo = excluded.DateTo, Providers = excluded.Providers, SnapshotAt = NULL`,
+		name, search.Cities, search.Genres, search.DateFrom, search.DateTo, strings.Join(search.Providers, ","), timestamp())
+	if err != nil {
+		return false, fmt.Errorf("failed to save search %s: %v", name, err)
+	}
+	if _, err := tx.Exec("DELETE FROM SearchSnapshots WHERE SavedSearchID = (SELECT ID FROM SavedSearches WHERE Name = ?)", name); err != nil {
+		return false, fmt.Errorf("failed to clear search snapshot: %v", err)
+	}
+	return replaced, tx.Commit()
+}
+
+/*
+GetSavedSearch returns the saved search with the name.
+Returns:
+- error: ErrSavedSearchNotFound if there is no saved search with the name.
+*/
+func GetSavedSearch(db *sql.DB, name string) (SavedSearch, error) {
+	row := db.QueryRow("SELECT "+savedSearchColumns+" FROM SavedSearches WHERE Name = ?", strings.TrimSpace(name))
+	search, err := scanSavedSearch(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return search, fmt.Errorf("%w %s", ErrSavedSearchNotFound, name)
+	}
+	return search, err
+}
+
+/*
+GetSavedSearches returns every saved search ordered by name.
+*/
+func GetSavedSearches(db *sql.DB) ([]SavedSearch, error) {
+	rows, err := db.Query("SELECT " + savedSearchColumns + " FROM SavedSearches ORDER BY Name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query saved searches from the database: %v", err)
+	}
+	defer rows.Close()
+	var searches []SavedSearch
+	for rows.Next() {
+		search, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		searches = append(searches, search)
+	}
+	return searches, rows.Err()
+}
+
+/*
+DeleteSavedSearch deletes the saved search with the name and the results kept from its runs.
+Returns:
+- error: ErrSavedSearchNotFound if there is no saved search with the name.
+*/
+func DeleteSavedSearch(db *sql.DB, name string) error {
+	search, err := GetSavedSearch(db, name)
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM SearchSnapshots WHERE SavedSearchID = ?", search.ID); err != nil {
+		return fmt.Errorf("failed to delete search snapshot: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM SavedSearches WHERE ID = ?", search.ID); err != nil {
+		return fmt.Errorf("failed to delete saved search: %v", err)
+	}
+	return tx.Commit()
+}
+
+/*
+SetSavedSearchRun records that the saved search with the id has been run.
+*/
+func SetSavedSearchRun(db *sql.DB, id int64) error {
+	_, err := db.Exec("UPDATE SavedSearches SET LastRunAt = ? WHERE ID = ?", timestamp(), id)
+	return err
+}
+
+// columns selected for a SavedSearch, in the order scanSavedSearch reads them
+const savedSearchColumns = "ID, Name, Cities, Genres, DateFrom, DateTo, Providers, CreatedAt, COALESCE(LastRunAt, ''), COALESCE(SnapshotAt, '')"
+
+// scans a row selected with savedSearchColumns into a SavedSearch
+func scanSavedSearch(row scanner) (SavedSearch, error) {
+	var search SavedSearch
+	var providers, createdAt, lastRunAt, snapshotAt string
+	err := row.Scan(&search.ID, &search.Name, &search.Cities, &search.Genres, &search.DateFrom, &search.DateTo, &providers, &createdAt, &lastRunAt, &snapshotAt)
+	if err != nil {
+		return search, fmt.Errorf("failed to scan saved search row: %w", err)
+	}
+	if providers != "" {
+		search.Providers = strings.Split(providers, ",")
+	}
+	search.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	search.LastRunAt, _ = time.Parse(time.RFC3339, lastRunAt)
+	search.SnapshotAt, _ = time.Parse(time.RFC3339, snapshotAt)
+	return search, nil
+}
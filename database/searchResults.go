@@ -0,0 +1,145 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrResultNotFound is returned when a ref is not in the results of the last search.
+var ErrResultNotFound = errors.New("search result not found")
+
+// SearchResult is a found event of the last search, cached so it can be added to the calendar by its ref.
+type SearchResult struct {
+	Ref       string
+	EventName string
+	// start of the event, the date alone when the time is not known
+	Start  time.Time
+	End    time.Time
+	AllDay bool
+	City   string
+	Genre  string
+	// provider of the listing and its ticket link
+	Provider string
+	Tickets  string
+}
+
+/*
+SaveSearchResults replaces the cached results of the last search.
+Parameters:
+- results: []SearchResult: the events found by the search.
+*/
+func SaveSearchResults(db *sql.DB, results []SearchResult) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM SearchResults"); err != nil {
+		return fmt.Errorf("failed to clear search results: %v", err)
+	}
+	// a ref found more than once keeps the first event, refs only repeat for the same listing
+	insert, err := tx.Prepare(`INSERT OR IGNORE INTO SearchResults (Ref, EventName, StartTime, EndTime, City, Genre, Provider, Tickets, SearchedAt)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	now := timestamp()
+	for _, result := range results {
+		_, err := insert.Exec(result.Ref, result.EventName, formatStoredTime(result.Start, result.AllDay), storedEndTime(result.End, result.AllDay),
+			result.City, result.Genre, result.Provider, result.Tickets, now)
+		if err != nil {
+			return fmt.Errorf("failed to cache search result: %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+/*
+GetSearchResult returns the cached result of the last search with the ref, a unique prefix of the ref is accepted. The prefix is compared as plain text so characters such as % and _ only match themselves.
+Returns:
+- error: ErrResultNotFound if no result or more than one result has the ref.
+*/
+func GetSearchResult(db *sql.DB, ref string) (SearchResult, error) {
+	ref = strings.ToLower(strings.TrimSpace(ref))
+	var result SearchResult
+	if ref == "" {
+		return result, fmt.Errorf("%w: missing ref", ErrResultNotFound)
+	}
+	rows, err := db.Query(`SELECT Ref, EventName, StartTime, COALESCE(EndTime, ''), COALESCE(City, ''), COALESCE(Genre, ''), COALESCE(Provider, ''), COALESCE(Tickets, '')
+		FROM SearchResults WHERE substr(Ref, 1, length(?)) = ?`, ref, ref)
+	if err != nil {
+		return result, fmt.Errorf("failed to query search results: %v", err)
+	}
+	defer rows.Close()
+	matches := 0
+	for rows.Next() {
+		var start, end string
+		if err := rows.Scan(&result.Ref, &result.EventName, &start, &end, &result.City, &result.Genre, &result.Provider, &result.Tickets); err != nil {
+			return result, fmt.Errorf("failed to scan search result row: %v", err)
+		}
+		result.Start, result.AllDay = parseStoredTime(start)
+		result.End, _ = parseStoredTime(end)
+		matches++
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+	switch {
+	case matches == 0:
+		return SearchResult{}, fmt.Errorf("%w: no event with ref %s in the last search results, search again to refresh them", ErrResultNotFound, ref)
+	case matches > 1:
+		return SearchResult{}, fmt.Errorf("%w: ref %s matches %d events, give more of the ref", ErrResultNotFound, ref, matches)
+	}
+	return result, nil
+}
+
+/*
+AddSearchResult adds the event of the last search with the ref to the calendar, keeping its ticket link, city, genre and provider.
+Returns:
+- CalendarEvent: the added event.
+- error: ErrResultNotFound if the ref is not in the last search results or ErrDuplicateEvent if the event is already in the calendar.
+*/
+func AddSearchResult(db *sql.DB, ref string) (CalendarEvent, error) {
+	result, err := GetSearchResult(db, ref)
+	if err != nil {
+		return CalendarEvent{}, err
+	}
+	start := formatStoredTime(result.Start, result.AllDay)
+	tx, err := db.Begin()
+	if err != nil {
+		return CalendarEvent{}, err
+	}
+	defer tx.Rollback()
+	j, err := startJournal(tx, "add")
+	if err != nil {
+		return CalendarEvent{}, err
+	}
+	now := timestamp()
+	query := `INSERT OR IGNORE INTO CalendarEvents (EventName, StartTime, EndTime, URL, City, Genre, Provider, UID, CreatedAt, UpdatedAt)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := tx.Exec(query, result.EventName, start, storedEndTime(result.End, result.AllDay), result.Tickets, result.City, result.Genre, result.Provider, newUID(), now, now)
+	if err != nil {
+		return CalendarEvent{}, fmt.Errorf("failed to add event to the database: %v", err)
+	}
+	if inserted, _ := res.RowsAffected(); inserted == 0 {
+		return CalendarEvent{}, fmt.Errorf("%w: %s on %s", ErrDuplicateEvent, result.EventName, start)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return CalendarEvent{}, err
+	}
+	if err := j.created(id); err != nil {
+		return CalendarEvent{}, err
+	}
+	if err := j.finish(fmt.Sprintf("added %s from search", result.EventName)); err != nil {
+		return CalendarEvent{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return CalendarEvent{}, fmt.Errorf("failed to commit event: %v", err)
+	}
+	return GetEvent(db, id)
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGetSearchResult(t *testing.T) {
+	if err := SetLocation(t.TempDir(), "test"); err != nil {
+		t.Fatal(err)
+	}
+	db, err := InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	start := time.Date(2026, 11, 5, 19, 0, 0, 0, time.UTC)
+	results := []SearchResult{
+		{Ref: "3fa92c01", EventName: "Gig", Start: start},
+		{Ref: "3fb10d77", EventName: "Play", Start: start},
+		{Ref: "a1b2c3d4", EventName: "Quiz", Start: start},
+	}
+	if err := SaveSearchResults(db, results); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ref string
+		// name of the event found, "" if the ref must not be found
+		want string
+	}{
+		{ref: "3fa92c01", want: "Gig"},
+		{ref: " 3FB ", want: "Play"},
+		{ref: "a", want: "Quiz"},
+		{ref: "3f"},
+		{ref: "ff"},
+		{ref: ""},
+		{ref: "%"},
+		{ref: "_"},
+		{ref: "a1b2%"},
+		{ref: "a_b2"},
+	}
+	for _, test := range tests {
+		result, err := GetSearchResult(db, test.ref)
+		if test.want == "" {
+			if !errors.Is(err, ErrResultNotFound) {
+				t.Errorf("GetSearchResult(%q) = %s, %v, want ErrResultNotFound", test.ref, result.EventName, err)
+			}
+			continue
+		}
+		if err != nil || result.EventName != test.want {
+			t.Errorf("GetSearchResult(%q) = %s, %v, want %s", test.ref, result.EventName, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SnapshotEvent is an event found by the last run of a saved search, kept so the next run can report what changed.
+type SnapshotEvent struct {
+	// key of the event that stays the same when its date or ticket link changes
+	Fingerprint string
+	EventName   string
+	Date        time.Time
+	// start and end times of the event, zero if they are not known
+	Start time.Time
+	End   time.Time
+	City  string
+	Venue string
+	Genre string
+	// provider and ticket link of every listing of the event, in the same order
+	Providers []string
+	Tickets   []string
+}
+
+/*
+ReplaceSnapshot replaces the results kept for a saved search with the events found by its latest run.
+Parameters:
+- id: int64: the id of the saved search.
+- events: []SnapshotEvent: the events found by the run.
+*/
+func ReplaceSnapshot(db *sql.DB, id int64, events []SnapshotEvent) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM SearchSnapshots WHERE SavedSearchID = ?", id); err != nil {
+		return fmt.Errorf("failed to clear search snapshot: %v", err)
+	}
+	insert, err := tx.Prepare(`INSERT INTO SearchSnapshots (SavedSearchID, Fingerprint, EventName, EventDate, StartTime, EndTime, City, Venue, Genre, Providers, Tickets)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	for _, event := range events {
+		_, err := insert.Exec(id, event.Fingerprint, event.EventName, formatStoredTime(event.Date, true), storedStartTime(event.Start, false), storedEndTime(event.End, false),
+			event.City, event.Venue, event.Genre, strings.Join(event.Providers, " "), strings.Join(event.Tickets, " "))
+		if err != nil {
+			return fmt.Errorf("failed to save search snapshot: %v", err)
+		}
+	}
+	if _, err := tx.Exec("UPDATE SavedSearches SET SnapshotAt = ? WHERE ID = ?", timestamp(), id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+/*
+GetSnapshot returns the results kept for a saved search ordered by date.
+Parameters:
+- id: int64: the id of the saved search.
+Returns:
+- []SnapshotEvent: the events found by the last run, empty if the search has not been run.
+- error: if the snapshot could not be read.
+*/
+func GetSnapshot(db *sql.DB, id int64) ([]SnapshotEvent, error) {
+	rows, err := db.Query(`SELECT Fingerprint, EventName, EventDate, COALESCE(StartTime, ''), COALESCE(EndTime, ''), COALESCE(City, ''), COALESCE(Venue, ''), COALESCE(Genre, ''),
+		COALESCE(Providers, ''), COALESCE(Tickets, '') FROM SearchSnapshots WHERE SavedSearchID = ? ORDER BY EventDate, StartTime`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query search snapshot from the database: %v", err)
+	}
+	defer rows.Close()
+	var events []SnapshotEvent
+	for rows.Next() {
+		var event SnapshotEvent
+		var date, start, end, providers, tickets string
+		if err := rows.Scan(&event.Fingerprint, &event.EventName, &date, &start, &end, &event.City, &event.Venue, &event.Genre, &providers, &tickets); err != nil {
+			return nil, fmt.Errorf("failed to scan search snapshot row: %v", err)
+		}
+		event.Date, _ = parseStoredTime(date)
+		event.Start, _ = parseStoredTime(start)
+		event.End, _ = parseStoredTime(end)
+		if providers != "" {
+			event.Providers, event.Tickets = strings.Split(providers, " "), strings.Split(tickets, " ")
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,259 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// errors returned when changing subscriptions, check them with errors.Is
+var (
+	ErrSubscriptionNotFound  = errors.New("no subscription named")
+	ErrDuplicateSubscription = errors.New("a subscription with the same name already exists")
+)
+
+// Subscription is a remote or local iCalendar file whose events are cached in the SubscriptionEvents table and checked for clashes alongside the calendar.
+type Subscription struct {
+	ID   int64
+	Name string
+	// url or file path of the iCalendar file
+	Source string
+	// when the events were last refreshed, zero if they never have been
+	RefreshedAt time.Time
+	// error of the last refresh, "" if it succeeded
+	LastError string
+	// number of cached events
+	Events    int
+	CreatedAt time.Time
+}
+
+// SubscriptionEvent is a cached event of a subscription, subscription events are read only and replaced on every refresh.
+type SubscriptionEvent struct {
+	Subscription string
+	UID          string
+	EventName    string
+	Start        time.Time
+	End          time.Time
+	AllDay       bool
+	// repeat rule and except dates of a repeating event, it is expanded to its occurrences when read
+	RRule   string
+	ExDates []time.Time
+}
+
+/*
+AddSubscription stores a new subscription, its events are added by RefreshSubscription.
+Parameters:
+- name: string: the name used to refer to the subscription.
+- source: string: the url or file path of the iCalendar file.
+Returns:
+- Subscription: the stored subscription.
+- error: ErrInvalidEvent if the name or source is empty or ErrDuplicateSubscription if the name is taken.
+*/
+func AddSubscription(db *sql.DB, name string, source string) (Subscription, error) {
+	name, source = strings.TrimSpace(name), strings.TrimSpace(source)
+	if name == "" || source == "" {
+		return Subscription{}, fmt.Errorf("%w: a subscription needs a name and a source", ErrInvalidEvent)
+	}
+	if _, err := GetSubscription(db, name); err == nil {
+		return Subscription{}, fmt.Errorf("%w: %s", ErrDuplicateSubscription, name)
+	}
+	res, err := db.Exec("INSERT INTO Subscriptions (Name, Source, CreatedAt) VALUES (?, ?, ?)", name, source, timestamp())
+	if err != nil {
+		return Subscription{}, fmt.Errorf("failed to add subscription to the database: %v", err)
+	}
+	id, _ := res.LastInsertId()
+	return Subscription{ID: id, Name: name, Source: source, CreatedAt: time.Now().UTC()}, nil
+}
+
+/*
+GetSubscriptions returns every subscription ordered by name.
+*/
+func GetSubscriptions(db *sql.DB) ([]Subscription, error) {
+	rows, err := db.Query("SELECT " + subscriptionColumns + " FROM Subscriptions ORDER BY Name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subscriptions from the database: %v", err)
+	}
+	defer rows.Close()
+	var subscriptions []Subscription
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, rows.Err()
+}
+
+/*
+GetSubscription returns the subscription with the name.
+Returns:
+- error: ErrSubscriptionNotFound if there is no subscription with the name.
+*/
+func GetSubscription(db *sql.DB, name string) (Subscription, error) {
+	row := db.QueryRow("SELECT "+subscriptionColumns+" FROM Subscriptions WHERE Name = ?", strings.TrimSpace(name))
+	subscription, err := scanSubscription(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return subscription, fmt.Errorf("%w %s", ErrSubscriptionNotFound, name)
+	}
+	return subscription, err
+}
+
+/*
+DeleteSubscription deletes the subscription with the name and its cached events.
+Returns:
+- error: ErrSubscriptionNotFound if there is no subscription with the name.
+*/
+func DeleteSubscription(db *sql.DB, name string) error {
+	subscription, err := GetSubscription(db, name)
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM SubscriptionEvents WHERE SubscriptionID = ?", subscription.ID); err != nil {
+		return fmt.Errorf("failed to delete subscription events: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM Subscriptions WHERE ID = ?", subscription.ID); err != nil {
+		return fmt.Errorf("failed to delete subscription: %v", err)
+	}
+	return tx.Commit()
+}
+
+/*
+ReplaceSubscriptionEvents replaces the cached events of a subscription with the events of a successful refresh.
+Parameters:
+- id: int64: the id of the subscription.
+- events: []SubscriptionEvent: the events read from the source, the Subscription field is ignored.
+*/
+func ReplaceSubscriptionEvents(db *sql.DB, id int64, events []SubscriptionEvent) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM SubscriptionEvents WHERE SubscriptionID = ?", id); err != nil {
+		return fmt.Errorf("failed to clear subscription events: %v", err)
+	}
+	insert, err := tx.Prepare("INSERT INTO SubscriptionEvents (SubscriptionID, UID, EventName, StartTime, EndTime, RRule, ExDates) VALUES (?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	for _, event := range events {
+		if event.Start.IsZero() {
+			continue
+		}
+		var rrule any
+		if event.RRule != "" {
+			rrule = event.RRule
+		}
+		_, err := insert.Exec(id, event.UID, event.EventName, formatStoredTime(event.Start, event.AllDay), storedEndTime(event.End, event.AllDay), rrule, storedExDates(event.ExDates))
+		if err != nil {
+			return fmt.Errorf("failed to cache subscription event: %v", err)
+		}
+	}
+	if _, err := tx.Exec("UPDATE Subscriptions SET RefreshedAt = ?, LastError = NULL WHERE ID = ?", timestamp(), id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+/*
+SetSubscriptionError records a failed refresh, the cached events of the last successful refresh are kept.
+*/
+func SetSubscriptionError(db *sql.DB, id int64, refreshErr error) error {
+	_, err := db.Exec("UPDATE Subscriptions SET LastError = ? WHERE ID = ?", refreshErr.Error(), id)
+	return err
+}
+
+/*
+GetSubscriptionEvents returns the cached events of every subscription starting in the range ordered by start. Repeating events are expanded to their occurrences in the range like ListEvents, to the end of the range or recurrenceHorizonYears when there is no end.
+Parameters:
+- from: time.Time: the first date, zero for no limit.
+- to: time.Time: the date to stop before, zero for no limit.
+*/
+func GetSubscriptionEvents(db *sql.DB, from time.Time, to time.Time) ([]SubscriptionEvent, error) {
+	query := `SELECT s.Name, COALESCE(e.UID, ''), e.EventName, e.StartTime, COALESCE(e.EndTime, ''), COALESCE(e.RRule, ''), COALESCE(e.ExDates, '')
+		FROM SubscriptionEvents e JOIN Subscriptions s ON s.ID = e.SubscriptionID
+		ORDER BY e.StartTime`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subscription events from the database: %v", err)
+	}
+	defer rows.Close()
+	var events []SubscriptionEvent
+	for rows.Next() {
+		var event SubscriptionEvent
+		var start, end, exDates string
+		if err := rows.Scan(&event.Subscription, &event.UID, &event.EventName, &start, &end, &event.RRule, &exDates); err != nil {
+			return nil, fmt.Errorf("failed to scan subscription event row: %v", err)
+		}
+		event.Start, event.AllDay = parseStoredTime(start)
+		event.End, _ = parseStoredTime(end)
+		event.ExDates = parseExDates(exDates)
+		events = append(events, event.occurrences(from, to)...)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events, nil
+}
+
+// the occurrences of a subscription event starting in the range, expanded with the rules of calendar events
+func (e SubscriptionEvent) occurrences(from time.Time, to time.Time) []SubscriptionEvent {
+	calendarEvent := CalendarEvent{
+		EventName: e.EventName,
+		Start:     e.Start,
+		End:       e.End,
+		AllDay:    e.AllDay,
+		Date:      e.Start.Format(time.DateOnly),
+		RRule:     e.RRule,
+		ExDates:   e.ExDates,
+	}
+	if !calendarEvent.Repeats() {
+		// compared as stored like the query of ListEvents, so an event on the first date is in the range whatever its time
+		start := formatStoredTime(e.Start, e.AllDay)
+		if (!from.IsZero() && start < from.Format(storedDateFormat)) || (!to.IsZero() && start >= to.Format(storedDateFormat)) {
+			return nil
+		}
+		return []SubscriptionEvent{e}
+	}
+	if to.IsZero() {
+		start := from
+		if start.IsZero() {
+			start = time.Now()
+		}
+		to = start.AddDate(recurrenceHorizonYears, 0, 0)
+	}
+	var occurrences []SubscriptionEvent
+	for _, occurrence := range calendarEvent.Occurrences(from, to) {
+		event := e
+		event.Start, event.End = occurrence.Start, occurrence.End
+		occurrences = append(occurrences, event)
+	}
+	return occurrences
+}
+
+// columns selected for a Subscription, in the order scanSubscription reads them
+const subscriptionColumns = "ID, Name, Source, COALESCE(RefreshedAt, ''), COALESCE(LastError, ''), (SELECT COUNT(*) FROM SubscriptionEvents WHERE SubscriptionID = Subscriptions.ID), CreatedAt"
+
+// scans a row selected with subscriptionColumns into a Subscription
+func scanSubscription(row scanner) (Subscription, error) {
+	var subscription Subscription
+	var refreshedAt, createdAt string
+	err := row.Scan(&subscription.ID, &subscription.Name, &subscription.Source, &refreshedAt, &subscription.LastError, &subscription.Events, &createdAt)
+	if err != nil {
+		return subscription, fmt.Errorf("failed to scan subscription row: %w", err)
+	}
+	subscription.RefreshedAt, _ = time.Parse(time.RFC3339, refreshedAt)
+	subscription.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return subscription, nil
+}
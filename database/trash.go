@@ -0,0 +1,82 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+/*
+GetTrash returns the deleted events in the trash, most recently deleted first.
+Returns:
+- []CalendarEvent: the deleted events, DeletedAt is when each was deleted.
+- error: if the events could not be read.
+*/
+func GetTrash(db *sql.DB) ([]CalendarEvent, error) {
+	query := "SELECT " + eventColumns + " FROM CalendarEvents WHERE DeletedAt IS NOT NULL ORDER BY DeletedAt DESC, ID"
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the trash: %v", err)
+	}
+	defer rows.Close()
+	var events []CalendarEvent
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+/*
+RestoreEvent moves the event with the id out of the trash back into the calendar, the restore is recorded in the journal so it can be undone.
+Returns:
+- CalendarEvent: the restored event.
+- error: ErrEventNotFound if there is no event with the id in the trash or ErrDuplicateEvent if an event with the same name and start has been added since it was deleted.
+*/
+func RestoreEvent(db *sql.DB, id int64) (CalendarEvent, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return CalendarEvent{}, err
+	}
+	defer tx.Rollback()
+
+	event, err := scanEvent(tx.QueryRow("SELECT "+eventColumns+" FROM CalendarEvents WHERE ID = ? AND DeletedAt IS NOT NULL", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return event, fmt.Errorf("%w: %d is not in the trash", ErrEventNotFound, id)
+	}
+	if err != nil {
+		return event, err
+	}
+	start := formatStoredTime(event.Start, event.AllDay)
+	var duplicateID int64
+	err = tx.QueryRow("SELECT ID FROM CalendarEvents WHERE EventName = ? AND StartTime = ? AND "+notDeleted, event.EventName, start).Scan(&duplicateID)
+	if err == nil {
+		return event, fmt.Errorf("%w: event %d", ErrDuplicateEvent, duplicateID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return event, fmt.Errorf("failed to check for duplicate events: %v", err)
+	}
+
+	j, err := startJournal(tx, "restore")
+	if err != nil {
+		return event, err
+	}
+	if err := j.changed(id); err != nil {
+		return event, err
+	}
+	if _, err := tx.Exec("UPDATE CalendarEvents SET DeletedAt = NULL, UpdatedAt = ? WHERE ID = ?", timestamp(), id); err != nil {
+		return event, fmt.Errorf("failed to restore event %d: %v", id, err)
+	}
+	if err := j.finish(fmt.Sprintf("restored %s from the trash", event.EventName)); err != nil {
+		return event, err
+	}
+	if err := tx.Commit(); err != nil {
+		return event, fmt.Errorf("failed to commit restore: %v", err)
+	}
+	event.DeletedAt = time.Time{}
+	return event, nil
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EventUpdate holds the fields of a calendar event to change, nil fields are left as they are.
+type EventUpdate struct {
+	EventName *string
+	// new date and optional times of the event in any format accepted by ParseEventTime, replaces the start and end
+	Date  *string
+	Venue *string
+}
+
+/*
+UpdateEvent changes the fields of the event with the id that are set in the update.
+Parameters:
+- id: int64: the id of the event to update.
+- update: EventUpdate: the fields to change.
+Returns:
+- bool: true if the row was changed, false if the event already had those details.
+- error: ErrEventNotFound if there is no event with the id, ErrInvalidEvent if a field is invalid or ErrDuplicateEvent if another event already has the same name and start.
+*/
+func UpdateEvent(db *sql.DB, id int64, update EventUpdate) (bool, error) {
+	existing, err := GetEvent(db, id)
+	if err != nil {
+		return false, err
+	}
+
+	// apply the update to the stored values
+	eventName := existing.EventName
+	start := formatStoredTime(existing.Start, existing.AllDay)
+	end := storedEndTime(existing.End, existing.AllDay)
+	venue := existing.Venue
+	if update.EventName != nil {
+		eventName = strings.TrimSpace(*update.EventName)
+		if eventName == "" {
+			return false, fmt.Errorf("%w: event name cannot be empty", ErrInvalidEvent)
+		}
+	}
+	if update.Date != nil {
+		newStart, newEnd, allDay, err := ParseEventTime(*update.Date)
+		if err != nil {
+			return false, err
+		}
+		start = formatStoredTime(newStart, allDay)
+		end = storedEndTime(newEnd, allDay)
+	}
+	if update.Venue != nil {
+		venue = strings.TrimSpace(*update.Venue)
+	}
+
+	// nothing to change
+	if eventName == existing.EventName && start == formatStoredTime(existing.Start, existing.AllDay) && end == storedEndTime(existing.End, existing.AllDay) && venue == existing.Venue {
+		return false, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// refuse updates that would make the event an exact duplicate of another
+	var duplicateID int64
+	err = tx.QueryRow("SELECT ID FROM CalendarEvents WHERE EventName = ? AND StartTime = ? AND ID != ? AND "+notDeleted, eventName, start, id).Scan(&duplicateID)
+	if err == nil {
+		return false, fmt.Errorf("%w: event %d", ErrDuplicateEvent, duplicateID)
+	}
+	if err != sql.ErrNoRows {
+		return false, fmt.Errorf("failed to check for duplicate events: %v", err)
+	}
+
+	// record the previous details so the update can be undone
+	j, err := startJournal(tx, "update")
+	if err != nil {
+		return false, err
+	}
+	if err := j.changed(id); err != nil {
+		return false, err
+	}
+
+	// query to update the event
+	query := "UPDATE CalendarEvents SET EventName = ?, StartTime = ?, EndTime = ?, Venue = ?, UpdatedAt = ? WHERE ID = ?"
+	res, err := tx.Exec(query, eventName, start, end, venue, timestamp(), id)
+	if err != nil {
+		return false, fmt.Errorf("failed to update event in the database: %v", err)
+	}
+	changed, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if err := j.finish(fmt.Sprintf("updated event %d %s", id, existing.EventName)); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit update: %v", err)
+	}
+	return changed > 0, nil
+}
+
+// formats a time for the StartTime or EndTime columns
+func formatStoredTime(t time.Time, allDay bool) string {
+	if allDay {
+		return t.Format(storedDateFormat)
+	}
+	return t.Format(storedDateTimeFormat)
+}
@@ -0,0 +1,233 @@
+package eventinput
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/ben-23-96/go_events_cli/database"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of event files read by Parse.
+var Formats = []string{"yaml", "json", "csv"}
+
+// fields of an event, in the order they are written in an -event flag
+var fields = []string{"name", "date", "venue", "repeat"}
+
+// Entry is an event read from the input with where it came from, so the result of adding it can be reported against the input.
+type Entry struct {
+	// where the event was read from, eg "row 3" or "-event 2"
+	Source string
+	Event  database.NewEvent
+}
+
+/*
+ParseSpec parses the value of an -event flag, fields written as key=value seperated by commas, eg "name=Rock, Paper, Scissors Tour,date=2023-11-05 19:30,venue=O2,repeat=weekly;until=2024-01-01". A comma only starts a new field when it is followed by a field name and =, so values can contain commas.
+Returns:
+- database.NewEvent: the event.
+- error: if a field is unknown or given twice.
+*/
+func ParseSpec(spec string) (database.NewEvent, error) {
+	var event database.NewEvent
+	seen := map[string]bool{}
+	for _, field := range splitFields(spec) {
+		key, value, ok := strings.Cut(field, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || !isField(key) {
+			return event, fmt.Errorf("invalid field %q, expected name=, date=, venue= or repeat=", strings.TrimSpace(field))
+		}
+		if seen[key] {
+			return event, fmt.Errorf("%s given more than once", key)
+		}
+		seen[key] = true
+		setField(&event, key, strings.TrimSpace(value))
+	}
+	return event, nil
+}
+
+// splits an -event value on the commas that are followed by a field name
+func splitFields(spec string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(spec); i++ {
+		if spec[i] != ',' {
+			continue
+		}
+		rest := strings.ToLower(strings.TrimLeft(spec[i+1:], " "))
+		for _, field := range fields {
+			if strings.HasPrefix(rest, field+"=") {
+				parts = append(parts, spec[start:i])
+				start = i + 1
+				break
+			}
+		}
+	}
+	return append(parts, spec[start:])
+}
+
+// reports whether key is the name of an event field
+func isField(key string) bool {
+	for _, field := range fields {
+		if key == field {
+			return true
+		}
+	}
+	return false
+}
+
+// sets the field of the event with the name key
+func setField(event *database.NewEvent, key string, value string) {
+	switch key {
+	case "name":
+		event.EventName = value
+	case "date":
+		event.Date = value
+	case "venue":
+		event.Venue = value
+	case "repeat":
+		event.Repeat = value
+	}
+}
+
+/*
+FormatOf returns the format of an event file from its extension.
+Returns:
+- string: one of Formats, "" if the extension is not recognised.
+*/
+func FormatOf(fileName string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".json":
+		return "json"
+	case ".csv":
+		return "csv"
+	}
+	return ""
+}
+
+/*
+Parse reads the events of a yaml, json or csv file. Yaml and json files hold a list of events with name, date, venue and repeat fields, or an object with the list in an events field. Csv files have a header row naming the name, date, venue and repeat columns.
+Parameters:
+- r: io.Reader: the file.
+- format: string: one of Formats, "" to detect the format from the content.
+Returns:
+- []Entry: the events in the order they are in the file, the events are not validated.
+- error: if the file is not valid in the format.
+*/
+func Parse(r io.Reader, format string) ([]Entry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = detectFormat(data)
+	}
+	switch format {
+	case "yaml":
+		return parseYAML(data)
+	case "json":
+		return parseJSON(data)
+	case "csv":
+		return parseCSV(data)
+	}
+	return nil, fmt.Errorf("unknown event file format %s, supported formats are %s", format, strings.Join(Formats, ", "))
+}
+
+// guesses the format of input without a file extension, eg from stdin
+func detectFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("[")) || bytes.HasPrefix(trimmed, []byte("{")) {
+		return "json"
+	}
+	firstLine, _, _ := bytes.Cut(trimmed, []byte("\n"))
+	if bytes.Contains(firstLine, []byte(",")) && !bytes.Contains(firstLine, []byte(":")) {
+		return "csv"
+	}
+	return "yaml"
+}
+
+// a yaml or json file is a list of events or an object with an events list
+type eventList struct {
+	Events []database.NewEvent `json:"events" yaml:"events"`
+}
+
+func parseYAML(data []byte) ([]Entry, error) {
+	var events []database.NewEvent
+	if err := yaml.Unmarshal(data, &events); err != nil {
+		var list eventList
+		if errList := yaml.Unmarshal(data, &list); errList != nil {
+			return nil, fmt.Errorf("invalid yaml: %v", err)
+		}
+		events = list.Events
+	}
+	return entries(events, "event"), nil
+}
+
+func parseJSON(data []byte) ([]Entry, error) {
+	var events []database.NewEvent
+	if err := json.Unmarshal(data, &events); err != nil {
+		var list eventList
+		if errList := json.Unmarshal(data, &list); errList != nil {
+			return nil, fmt.Errorf("invalid json: %v", err)
+		}
+		events = list.Events
+	}
+	return entries(events, "event"), nil
+}
+
+func parseCSV(data []byte) ([]Entry, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+	// rows can leave off empty trailing columns
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv: %v", err)
+	}
+	columns := map[int]string{}
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !isField(column) {
+			return nil, fmt.Errorf("invalid csv: unknown column %q, expected name, date, venue and repeat", column)
+		}
+		columns[i] = column
+	}
+	var parsed []Entry
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+		var event database.NewEvent
+		for i, value := range record {
+			if column, ok := columns[i]; ok {
+				setField(&event, column, strings.TrimSpace(value))
+			}
+		}
+		parsed = append(parsed, Entry{Source: fmt.Sprintf("line %d", line), Event: event})
+	}
+	return parsed, nil
+}
+
+// numbers events from 1 in the order they were read
+func entries(events []database.NewEvent, source string) []Entry {
+	var parsed []Entry
+	for i, event := range events {
+		parsed = append(parsed, Entry{Source: fmt.Sprintf("%s %d", source, i+1), Event: event})
+	}
+	return parsed
+}
@@ -1,78 +1,136 @@
 package eventsearch
 
 import (
+	"context"
+	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"sort"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/codingsince1985/geo-golang"
 	"github.com/codingsince1985/geo-golang/opencage"
-	"github.com/hbollon/go-edlib"
 )
 
+// default cap on the number of results collected from the pages of each request
+const DefaultMaxResults = 500
+
+// default time allowed for each API request before it is abandoned
+const DefaultRequestTimeout = 20 * time.Second
+
 type ApiSearch struct {
-	Cities             string
-	Genres             string
-	DateFrom           string
-	DateTo             string
-	Ticketmaster       bool
-	Skiddle            bool
-	foundEventsChannel chan []FoundEvent
-	dateFromSkiddle    string
-	dateToSkiddle      string
-	longitude          string
-	latitude           string
-	ticketmasterGenre  string
-	skiddleGenreID     string
+	Cities         string
+	Genres         string
+	DateFrom       string
+	DateTo         string
+	Providers      []string
+	MaxResults     int
+	RequestTimeout time.Duration
+	// keep every listing rather than merging duplicates with MergeDuplicates
+	KeepDuplicates bool
+	resultsChannel chan requestResult
+	dateFrom       time.Time
+	dateTo         time.Time
 }
 
 /*
-Searches for events from the ticketmaster and skiddle API's using parameter provided in APISearch{}
+Searches for events from every selected provider using parameter provided in APISearch{}. Every request reports back a result or an error so a failing provider never stalls the search.
+Parameters:
+- ctx: context.Context: cancelling the context abandons geocoding and every in-flight request, the events already received are still returned.
 Returns:
-- []FoundEvent: A slice of FoundEvent{} that contain all relevant information of events returned from the API's.
+- SearchResult: the events found sorted by date, with the report of each selected provider, the resolved genres and geocoded locations.
+- error: from Validate, the search is not made if the parameters are invalid.
 */
-func (s *ApiSearch) Search() []FoundEvent {
-	// check dates are in valid format and time
-	s.validateDates()
-	// find best match from user input genres to avaible genre params for skiddle and ticketmaster API's
-	s.matchGenres()
-	// find lng + lat of cities for use in indivual requests to skiddle API
-	citiesLngLat := s.skiddleLongLat()
-	wgValue := len(citiesLngLat) + 1
+func (s *ApiSearch) Search(ctx context.Context) (SearchResult, error) {
+	start := time.Now()
+	result := SearchResult{Genres: make(map[string][]string)}
+	// check cities, genres, dates and providers before making any request
+	if err := s.Validate(); err != nil {
+		return result, err
+	}
+	params := SearchParams{
+		Cities:   SplitList(s.Cities),
+		Genres:   SplitList(s.Genres),
+		DateFrom: s.dateFrom,
+		DateTo:   s.dateTo,
+	}
+	// find lng + lat of cities for providers that search around a location
+	var warnings []error
+	result.Locations, warnings = s.geocodeCities(ctx, params.Cities)
+	result.Warnings = append(result.Warnings, warnings...)
+	for _, cityLocation := range result.Locations {
+		params.Locations = append(params.Locations, cityLocation.Location)
+	}
+
+	providers, reports := s.selectedProviders()
+	// index of each provider's report
+	reportIndex := make(map[string]int)
+	for i, report := range reports {
+		reportIndex[report.Provider] = i
+	}
+
+	// build the requests of each selected provider
+	type providerRequest struct {
+		url      string
+		provider EventProvider
+	}
+	var requests []providerRequest
+	for _, provider := range providers {
+		// record the genres the provider matched the user genres to
+		if matcher, ok := provider.(GenreMatcher); ok && len(params.Genres) > 0 {
+			if genres, err := matcher.MatchGenres(params.Genres); err == nil {
+				result.Genres[provider.Name()] = genres
+			}
+		}
+		requestUrls, err := provider.BuildRequests(params)
+		if err != nil {
+			report := &reports[reportIndex[provider.Name()]]
+			report.Errors = append(report.Errors, fmt.Errorf("building requests: %w", err))
+			continue
+		}
+		for _, requestUrl := range requestUrls {
+			requests = append(requests, providerRequest{url: requestUrl, provider: provider})
+		}
+	}
+
 	// Create a channel for receiving the results from api's
-	s.foundEventsChannel = make(chan []FoundEvent, wgValue)
+	s.resultsChannel = make(chan requestResult, len(requests))
 	// create wait group
 	wg := &sync.WaitGroup{}
-	// set waitgroup limit to number of skiddle requests + ticketmaster request
-	wg.Add(wgValue)
-
-	// set ticketmaster url and unmarshalling function, then make request in goroutine (API handles list of cities)
-	ticketmasterUrl, ticketmasterUnmarshallFunc := s.setApi(s.Ticketmaster, false)
-	go s.makeRequest(ticketmasterUrl, ticketmasterUnmarshallFunc, wg)
-	// set skiddle url and unmarshalling function, then make request in goroutine for each city
-	for _, location := range citiesLngLat {
-		s.longitude = fmt.Sprintf("%f", location.Lng)
-		s.latitude = fmt.Sprintf("%f", location.Lat)
-		skiddleUrl, skiddleUnmarshallFunc := s.setApi(false, s.Skiddle)
-		go s.makeRequest(skiddleUrl, skiddleUnmarshallFunc, wg)
+	// set waitgroup limit to number of requests across all providers
+	wg.Add(len(requests))
+	// make each request in a goroutine
+	for _, request := range requests {
+		go s.makeRequest(ctx, request.url, request.provider, wg)
 	}
 
 	// Wait for goroutines to complete.
 	wg.Wait()
-	// Close the FoundEventsChannel after all goroutines are done.
-	close(s.foundEventsChannel)
+	// Close the resultsChannel after all goroutines are done.
+	close(s.resultsChannel)
 
-	// Collect results from the channel.
+	// Collect results from the channel, recording each result in its provider's report.
 	var foundEvents []FoundEvent
-	for events := range s.foundEventsChannel {
-		foundEvents = append(foundEvents, events...)
+	for requestRes := range s.resultsChannel {
+		report := &reports[reportIndex[requestRes.provider]]
+		report.Requests++
+		report.Events += len(requestRes.events)
+		report.RequestDurations = append(report.RequestDurations, requestRes.duration)
+		// requests run concurrently so the provider took as long as its slowest request
+		if requestRes.duration > report.Duration {
+			report.Duration = requestRes.duration
+		}
+		if requestRes.err != nil {
+			report.Failed++
+			report.Errors = append(report.Errors, requestRes.err)
+		}
+		foundEvents = append(foundEvents, requestRes.events...)
 	}
 
 	//slices.SortFunc(foundEvents, func(a, b T) int { return a.Date.Compare(B.Date) })
@@ -80,211 +138,186 @@ func (s *ApiSearch) Search() []FoundEvent {
 		return foundEvents[i].Date.Before(foundEvents[j].Date)
 	})
 
-	return foundEvents
+	// merge the same event listed by several providers or requests
+	if !s.KeepDuplicates {
+		foundEvents = MergeDuplicates(foundEvents)
+	}
+
+	result.Events = foundEvents
+	result.Providers = reports
+	result.Duration = time.Since(start)
+	return result, nil
 
 }
 
 /*
-Validates the user provided dateFrom and DateTo strings are in a valid dates and formats them in the required way to be used as query params in the API requests.
+Looks up the providers named in the Providers attribute in the registry, if no providers are named every registered provider is used.
+Returns:
+- []EventProvider: the providers to search.
+- []ProviderReport: an empty report for each provider, and a failed report for each name that is not registered.
 */
-func (s *ApiSearch) validateDates() {
-	// Check date in correct format
-	dateFrom, err := time.Parse(time.DateOnly, s.DateFrom)
-	if err != nil {
-		fmt.Printf("Error with date-from format: %s err: %s\n", s.DateFrom, err)
-		return
-	}
-	dateTo, err := time.Parse(time.DateOnly, s.DateTo)
-	if err != nil {
-		fmt.Printf("Error with date-from format: %s err: %s\n", s.DateTo, err)
-		return
+func (s *ApiSearch) selectedProviders() ([]EventProvider, []ProviderReport) {
+	names := s.Providers
+	if len(names) == 0 {
+		names = ProviderNames()
 	}
-
-	// Check if dateFrom is later than the current date
-	current := time.Now().Truncate(24 * time.Hour)
-	if dateFrom.Before(current) {
-		fmt.Println("dateFrom must be later than the current date")
-		return
-	}
-	// Check if dateFrom is earlier than dateTo
-	if dateFrom.After(dateTo) {
-		fmt.Println("dateFrom must be later than the dateTo")
-		return
+	var selected []EventProvider
+	var reports []ProviderReport
+	for _, name := range names {
+		provider, ok := GetProvider(name)
+		if !ok {
+			reports = append(reports, ProviderReport{Provider: name, Errors: []error{fmt.Errorf("unknown provider: %s", name)}})
+			continue
+		}
+		selected = append(selected, provider)
+		reports = append(reports, ProviderReport{Provider: provider.Name()})
 	}
-
-	// Format the date as a string in "YYYY-MM-DDT00:00:00Z" format
-	s.DateFrom = dateFrom.Format(time.RFC3339)
-	s.DateTo = dateTo.Format(time.RFC3339)
-	// Format the date as a string in "YYYY-MM-DD" format for skiddle
-	s.dateFromSkiddle = dateFrom.Format(time.DateOnly)
-	s.dateToSkiddle = dateTo.Format(time.DateOnly)
+	return selected, reports
 }
 
+// accepted genre params of each API, embedded so the package works from any directory
+//
+//go:embed genres.json
+var genresJSON []byte
+
 /*
-Uses the levenshtien algorithm to find the best match of the genres provided in the Genres attribute, and the accepted format of that genre to be sent as a query param to the relevant API. Accepted formats are stored in genres.json. Skiddle API requires genreID and ticketmaster API requires spelling + wording to be the same as exspected. Sets the ticketmasterGenres and skiddleGenreID attributes as string comma seperated lists.
+Reads the accepted genre params of each API from genres.json. Skiddle API requires genreID and ticketmaster API requires spelling + wording to be the same as exspected.
 */
-func (s *ApiSearch) matchGenres() {
-	// Read the JSON data from the genres.json file
-	genresJSON, err := os.ReadFile("eventsearch/genres.json")
-	if err != nil {
-		panic(err)
-	}
-
-	// Unmarshal the JSON data into the genres structure
+func loadGenres() (GenreJSON, error) {
 	var genres GenreJSON
+	// Unmarshal the JSON data into the genres structure
 	if err := json.Unmarshal(genresJSON, &genres); err != nil {
-		panic(err)
+		return genres, err
 	}
-
-	// Split the user input string by commas
-	userGenresList := strings.Split(s.Genres, ",")
-
-	var stringSimilarity float32
-	var bestMatchSkiddleID string
-	stringSimilarity = 0.0
-	// iterate over genre inputs
-	for _, userGenre := range userGenresList {
-		// find the ticketmaster genre that matches user input closest add to ticketmasterGenre attribute as comma seperated list
-		bestMatchTicketmasterGenre, _ := edlib.FuzzySearch(userGenre, genres.Ticketmaster.Genres, edlib.Levenshtein)
-		if s.ticketmasterGenre != "" {
-			s.ticketmasterGenre = s.ticketmasterGenre + "," + bestMatchTicketmasterGenre
-		} else {
-			s.ticketmasterGenre = bestMatchTicketmasterGenre
-		}
-
-		// find the skiddle genre that matches user input closest set its ID to skiddleGenreID sttribute
-		for _, skiddleGenre := range genres.Skiddle.Genres {
-			similarityRes, _ := edlib.StringsSimilarity(userGenre, skiddleGenre.Name, edlib.Levenshtein)
-			// if strings match exactly set best match break loop
-			if similarityRes == 1 {
-				bestMatchSkiddleID = skiddleGenre.ID
-				break
-			}
-			// current best match
-			if similarityRes > stringSimilarity {
-				stringSimilarity = similarityRes
-				bestMatchSkiddleID = skiddleGenre.ID
-			}
-		}
-		// add found genre id as part of comma seperated list to skiddleGenreID attribute and reset stringSimilarity and best match vars
-		if s.skiddleGenreID != "" {
-			s.skiddleGenreID = s.skiddleGenreID + "," + bestMatchSkiddleID
-		} else {
-			s.skiddleGenreID = bestMatchSkiddleID
-		}
-		stringSimilarity = 0.0
-		bestMatchSkiddleID = ""
-	}
-	fmt.Println(s.ticketmasterGenre)
+	return genres, nil
 }
 
 /*
-Uses the opencage API to find longitide and latitude of cities provided in Cities attribute.
+Uses the opencage API to find longitide and latitude of the cities. The geocoder SDK does not take a context so each lookup runs in a goroutine that is abandoned if the context is cancelled.
 Returns:
-- []geo.Location: slice containing the geo data on the provided cities.
+- []CityLocation: slice containing the geo data on the cities found before the context was cancelled.
+- []error: a warning for each city that could not be geocoded.
 */
-func (s *ApiSearch) skiddleLongLat() []geo.Location {
+func (s *ApiSearch) geocodeCities(ctx context.Context, cities []string) ([]CityLocation, []error) {
 	// geocoder SDK for finding lng and lat of city
 	geocoder := opencage.Geocoder(os.Getenv("opencageAPIKey"))
-	var citiesLngLat []geo.Location
-	// Split the input string by commas
-	citiesList := strings.Split(s.Cities, ",")
+	var citiesLngLat []CityLocation
+	var warnings []error
 	// find long and lat of each city append to slice
-	for _, city := range citiesList {
-		location, _ := geocoder.Geocode(city)
-		if location != nil {
-			citiesLngLat = append(citiesLngLat, *location)
+	for _, city := range cities {
+		type geocodeResult struct {
+			location *geo.Location
+			err      error
+		}
+		resultChannel := make(chan geocodeResult, 1)
+		go func(city string) {
+			location, err := geocoder.Geocode(city)
+			resultChannel <- geocodeResult{location: location, err: err}
+		}(city)
+
+		select {
+		case <-ctx.Done():
+			return citiesLngLat, warnings
+		case geocoded := <-resultChannel:
+			if geocoded.err != nil {
+				warnings = append(warnings, fmt.Errorf("could not geocode %s: %w", city, geocoded.err))
+			} else if geocoded.location == nil {
+				warnings = append(warnings, fmt.Errorf("could not geocode %s: no location found", city))
+			} else {
+				citiesLngLat = append(citiesLngLat, CityLocation{City: city, Location: *geocoded.location})
+			}
 		}
 	}
-	return citiesLngLat
+	return citiesLngLat, warnings
 }
 
 /*
-Creates a url with the user provided parameters for either ticketmaster or skiddle API, sets the relevant function to be used for unmarshall the API response.
+Makes a request to a API and follows the next page urls returned by the provider until the results are exhausted or MaxResults is reached. Each page is unmarshalled into []FoundEvent and the collected events are sent back to resultsChannel with the error that ended the request, if any. Pages received before a request failed or the context was cancelled are still sent.
 Parameters:
-- ticketmaster: bool: if true set for ticketmaster API.
-- skiddle: bool: if true set for skiddle API.const
-Returns:
-- requestUrl: string: url to be used in a API request with relevant query parameters.
-- unmarshallFunction: a function used to unmarshall the response json for a particular API request.
+- ctx: context.Context: the context of the search.
+- requestUrl: string: the url of the first page to make the request to.
+- provider: EventProvider: the provider the request was built by, used to parse the API json response.
+- wg: waitGroup: the wait group of the goroutine
 */
-func (s *ApiSearch) setApi(ticketmaster bool, skiddle bool) (requestUrl string, unmarshallFunction UnmarshalFunction) {
-	if ticketmaster {
-		apiKey := os.Getenv("ticketmasterAPIKey")
-		// set base ticketmaster url
-		requestUrl := fmt.Sprintf("https://app.ticketmaster.com/discovery/v2/events.json?apikey=%s", apiKey)
-		// set query params for api request
-		requestUrl += fmt.Sprintf("&city=%s", url.QueryEscape(s.Cities))
-		requestUrl += fmt.Sprintf("&classificationName=%s", url.QueryEscape(s.ticketmasterGenre))
-		requestUrl += fmt.Sprintf("&startDateTime=%s", url.QueryEscape(s.DateFrom))
-		requestUrl += fmt.Sprintf("&endDateTime=%s", url.QueryEscape(s.DateTo))
-		requestUrl += fmt.Sprintf("&size=%s", url.QueryEscape("100"))
-
-		// set the function used for unmarshalling the json response
-		unmarshalFunction := UnmarshalTicketmasterJSON
-		fmt.Println(requestUrl)
-		return requestUrl, unmarshalFunction
-	} else if skiddle {
-		apiKey := os.Getenv("skiddleAPIKey")
-		// set base skiddle url
-		requestUrl := fmt.Sprintf("https://www.skiddle.com/api/v1/events/search/?api_key=%s", apiKey)
-		// set query params for api request
-		requestUrl += fmt.Sprintf("&longitude=%s", url.QueryEscape(s.longitude))
-		requestUrl += fmt.Sprintf("&latitude=%s", url.QueryEscape(s.latitude))
-		requestUrl += fmt.Sprintf("&radius=%s", url.QueryEscape("8"))
-		requestUrl += fmt.Sprintf("&minDate=%s", url.QueryEscape(s.dateFromSkiddle))
-		requestUrl += fmt.Sprintf("&maxDate=%s", url.QueryEscape(s.dateToSkiddle))
-		requestUrl += fmt.Sprintf("&description=%s", url.QueryEscape("1"))
-		if s.skiddleGenreID != "" {
-			requestUrl += fmt.Sprintf("&g=%s", url.QueryEscape(s.skiddleGenreID))
+func (s *ApiSearch) makeRequest(ctx context.Context, requestUrl string, provider EventProvider, wg *sync.WaitGroup) {
+	// signal done to waitgroup however the request ends
+	defer wg.Done()
+	start := time.Now()
+	maxResults := s.MaxResults
+	if maxResults <= 0 {
+		maxResults = DefaultMaxResults
+	}
+	var events []FoundEvent
+	var requestErr error
+	for page := 1; requestUrl != "" && len(events) < maxResults; page++ {
+		body, err := s.fetch(ctx, requestUrl)
+		if err != nil {
+			requestErr = fmt.Errorf("page %d: %w", page, err)
+			break
 		}
-		//requestUrl += fmt.Sprintf("&limit=%s", url.QueryEscape("100"))
-		fmt.Printf("\n\n%s\n\n", requestUrl)
-		fmt.Println(s.skiddleGenreID)
-		// set the function used for unmarshalling the json response
-		unmarshalFunction := UnmarshalSkiddleJSON
-
-		return requestUrl, unmarshalFunction
+		// unmarshall the page into []FoundEvents and get the url of the next page
+		pageEvents, nextUrl, err := provider.ParseResponse(requestUrl, body)
+		if err != nil {
+			requestErr = fmt.Errorf("page %d: error unmarshalling response: %w", page, err)
+			break
+		}
+		events = append(events, pageEvents...)
+		requestUrl = nextUrl
+	}
+	// drop any results past the cap from the last page
+	if len(events) > maxResults {
+		events = events[:maxResults]
 	}
-	return "", nil
+	// record which provider found each event
+	for i := range events {
+		events[i].Provider = provider.Name()
+		events[i].Sources = []EventSource{{Provider: provider.Name(), Tickets: events[i].Tickets}}
+	}
+	// send the result to channel, it is buffered for every request so this never blocks
+	s.resultsChannel <- requestResult{provider: provider.Name(), events: events, err: requestErr, duration: time.Since(start)}
 }
 
 /*
-Makes a request to a API, unmarshalls the response into []FoundEvent and sends the unmarshalled data back to foundEventsChannel.
+Sends an HTTP GET request that is abandoned after RequestTimeout or when the context is cancelled.
 Parameters:
+- ctx: context.Context: the context of the search.
 - requestUrl: string: the url to make the request to.
-- unmarshallFunction: the function used to unmarsshall the API json response.const
-- wg: waitGroup: the wait group of the goroutine
+Returns:
+- []byte: the response body.
+- error: if the request failed, timed out or did not return status 200.
 */
-func (s *ApiSearch) makeRequest(requestUrl string, unmarshalFunction UnmarshalFunction, wg *sync.WaitGroup) {
+func (s *ApiSearch) fetch(ctx context.Context, requestUrl string) ([]byte, error) {
+	timeout := s.RequestTimeout
+	if timeout <= 0 {
+		timeout = DefaultRequestTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return nil, err
+	}
 	// Send an HTTP GET request
-	response, err := http.Get(requestUrl)
+	response, err := http.DefaultClient.Do(request)
 	if err != nil {
-		fmt.Println("Error:", err)
-		return
+		// drop the url from the error, it contains the API key
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, urlErr.Err
+		}
+		return nil, err
 	}
 	defer response.Body.Close()
 
-	// Check the response status code
-	if response.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(response.Body)
-		fmt.Printf("Request failed with status: %d, body:%s", response.StatusCode, body)
-		return
-	}
 	// read the response body
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
-		fmt.Println("error reading response body:", err)
-		return
+		return nil, fmt.Errorf("error reading response body: %v", err)
 	}
-	// unmarshall the response into []FoundEvents
-	events, err := unmarshalFunction(body)
-	if err != nil {
-		fmt.Println("error reading response body:", err)
-		return
+	// Check the response status code
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request failed with status: %d, body: %s", response.StatusCode, body)
 	}
-	// send []FoundEvents to channel
-	s.foundEventsChannel <- events
-	// signal done to waitgroup
-	wg.Done()
+	return body, nil
 }
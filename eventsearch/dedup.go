@@ -0,0 +1,131 @@
+package eventsearch
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/hbollon/go-edlib"
+)
+
+// minimum levenshtein similarity between the normalised names of two events on the same date in the same city for them to be merged
+const DuplicateSimilarityThreshold = 0.8
+
+// a shorter name contained in a longer one only counts as a duplicate if it is at least this long, so short names like "live" do not merge everything
+const minContainedNameLength = 6
+
+// EventSource is the listing of an event on one provider.
+type EventSource struct {
+	Provider string
+	Tickets  string
+}
+
+/*
+MergeDuplicates clusters events that are listed more than once, by the same provider or across providers, and merges each cluster into one event. Events are duplicates when they are on the same date in the same city and their names are similar, the merged event keeps the details of the first listing, fills any missing details from the others and carries the ticket link of every listing in Sources.
+Parameters:
+- events: []FoundEvent: the events to merge, the order is kept.
+Returns:
+- []FoundEvent: the merged events, every event has at least its own listing in Sources.
+*/
+func MergeDuplicates(events []FoundEvent) []FoundEvent {
+	var merged []FoundEvent
+	// normalised name of each merged event, so names are only normalised once
+	var mergedNames []string
+	for _, event := range events {
+		name := normaliseName(event.Name)
+		duplicateIndex := -1
+		for i, existing := range merged {
+			if isDuplicate(existing, mergedNames[i], event, name) {
+				duplicateIndex = i
+				break
+			}
+		}
+
+		if duplicateIndex == -1 {
+			if len(event.Sources) == 0 {
+				event.Sources = []EventSource{{Provider: event.Provider, Tickets: event.Tickets}}
+			}
+			merged = append(merged, event)
+			mergedNames = append(mergedNames, name)
+			continue
+		}
+		mergeInto(&merged[duplicateIndex], event)
+	}
+	return merged
+}
+
+// reports whether two events are on the same date, in the same city and have similar names
+func isDuplicate(a FoundEvent, aName string, b FoundEvent, bName string) bool {
+	if !a.Date.Equal(b.Date) {
+		return false
+	}
+	if !strings.EqualFold(strings.TrimSpace(a.City), strings.TrimSpace(b.City)) {
+		return false
+	}
+	if aName == bName {
+		return true
+	}
+	// a name with extra detail, eg "artist" and "artist + support"
+	shorter, longer := aName, bName
+	if len(shorter) > len(longer) {
+		shorter, longer = longer, shorter
+	}
+	if len(shorter) >= minContainedNameLength && strings.Contains(longer, shorter) {
+		return true
+	}
+	similarity, err := edlib.StringsSimilarity(aName, bName, edlib.Levenshtein)
+	return err == nil && similarity >= DuplicateSimilarityThreshold
+}
+
+// adds the listing of a duplicate to the merged event and fills in any details it is missing
+func mergeInto(merged *FoundEvent, duplicate FoundEvent) {
+	sources := duplicate.Sources
+	if len(sources) == 0 {
+		sources = []EventSource{{Provider: duplicate.Provider, Tickets: duplicate.Tickets}}
+	}
+	for _, source := range sources {
+		// the same listing returned twice, eg by skiddle searches around nearby cities
+		known := false
+		for _, existing := range merged.Sources {
+			if existing.Tickets == source.Tickets {
+				known = true
+				break
+			}
+		}
+		if !known {
+			merged.Sources = append(merged.Sources, source)
+		}
+	}
+	if merged.City == "" {
+		merged.City = duplicate.City
+	}
+	if merged.Venue == "" {
+		merged.Venue = duplicate.Venue
+	}
+	if merged.Genre == "" {
+		merged.Genre = duplicate.Genre
+	}
+	if merged.Subgenre == "" {
+		merged.Subgenre = duplicate.Subgenre
+	}
+	// not every provider gives times
+	if merged.Start.IsZero() {
+		merged.Start = duplicate.Start
+	}
+	if merged.End.IsZero() {
+		merged.End = duplicate.End
+	}
+}
+
+// lower cases a name and drops punctuation and repeated whitespace so listings of the same event compare equal
+func normaliseName(name string) string {
+	var builder strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			builder.WriteRune(r)
+		case unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r):
+			builder.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(builder.String()), " ")
+}
@@ -0,0 +1,50 @@
+package eventsearch
+
+import "time"
+
+// ProviderReport summarises how the requests of a provider went during a search.
+type ProviderReport struct {
+	Provider string
+	// number of requests made, each request may cover several pages
+	Requests int
+	// number of requests that failed, a failed request may still have returned some pages
+	Failed int
+	// number of events returned by the provider
+	Events int
+	// errors from building the requests and from each failed request
+	Errors []error
+	// time taken by the slowest request, requests run concurrently so this is how long the provider took
+	Duration time.Duration
+	// time taken by each request including all of its pages
+	RequestDurations []time.Duration
+}
+
+/*
+Reports whether the provider failed outright, every request failed or no requests could be made.
+*/
+func (r ProviderReport) AllFailed() bool {
+	return len(r.Errors) > 0 && r.Failed == r.Requests
+}
+
+/*
+Reports whether every provider in the search failed, used to decide the exit status of the search command.
+*/
+func AllProvidersFailed(reports []ProviderReport) bool {
+	if len(reports) == 0 {
+		return false
+	}
+	for _, report := range reports {
+		if !report.AllFailed() {
+			return false
+		}
+	}
+	return true
+}
+
+// result of a single provider request sent back to Search(), err is set if the request failed part way
+type requestResult struct {
+	provider string
+	events   []FoundEvent
+	err      error
+	duration time.Duration
+}
@@ -0,0 +1,111 @@
+package eventsearch
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codingsince1985/geo-golang"
+)
+
+/*
+EventProvider is a source of events that Search() fans out to. A provider builds the API requests for a search and parses each response into []FoundEvent, returning the url of the next page until the results are exhausted. Implement it and call RegisterProvider to make a new source available to ApiSearch and the -providers flag.
+*/
+type EventProvider interface {
+	// Name returns the name used to select the provider, eg "ticketmaster".
+	Name() string
+	// BuildRequests returns the urls to request for the search parameters.
+	BuildRequests(params SearchParams) ([]string, error)
+	// ParseResponse unmarshalls the response body of requestUrl into []FoundEvent and returns the url of the next page, or "" when there are no more pages.
+	ParseResponse(requestUrl string, body []byte) ([]FoundEvent, string, error)
+}
+
+// SearchParams holds the validated parameters of a search in the form passed to each EventProvider.
+type SearchParams struct {
+	Cities    []string
+	Genres    []string
+	Locations []geo.Location
+	DateFrom  time.Time
+	DateTo    time.Time
+}
+
+// registry of providers available to Search() keyed by lower case name
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]EventProvider{}
+)
+
+/*
+RegisterProvider adds a provider to the registry so it can be selected by name. Registering a provider with the same name as an existing one replaces it.
+*/
+func RegisterProvider(provider EventProvider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[strings.ToLower(provider.Name())] = provider
+}
+
+/*
+GetProvider returns the registered provider with the given name, names are case insensitive.
+Returns:
+- EventProvider: the provider.
+- bool: false if no provider is registered with that name.
+*/
+func GetProvider(name string) (EventProvider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	provider, ok := providers[strings.ToLower(strings.TrimSpace(name))]
+	return provider, ok
+}
+
+/*
+ProviderNames returns the names of all registered providers in alphabetical order.
+*/
+func ProviderNames() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	var names []string
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+SplitList splits a comma seperated list given on the command line, such as the cities, genres or providers of a search, trimming whitespace and dropping empty items.
+Returns:
+- []string: the items, nil if the list is empty.
+*/
+func SplitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+/*
+Sets a query parameter of a request url, used by providers to build the url of the next page.
+Parameters:
+- requestUrl: string: the url to modify.
+- key: string: the query parameter name.
+- value: int: the query parameter value.
+Returns:
+- string: the url with the query parameter set.
+*/
+func setQueryParam(requestUrl string, key string, value int) (string, error) {
+	parsedUrl, err := url.Parse(requestUrl)
+	if err != nil {
+		return "", err
+	}
+	query := parsedUrl.Query()
+	query.Set(key, strconv.Itoa(value))
+	parsedUrl.RawQuery = query.Encode()
+	return parsedUrl.String(), nil
+}
@@ -0,0 +1,103 @@
+package eventsearch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// weekdays by lower case name for next-<weekday> dates
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+/*
+ResolveDate turns a date in format YYYY-MM-DD or a relative date into a date at midnight UTC, the same form time.Parse(time.DateOnly) returns.
+Relative dates supported:
+- today, tomorrow
+- this-weekend, next-weekend: the saturday of the weekend, or the sunday when endOfRange is true.
+- next-<weekday>: the next occurrence of the weekday after today, eg next-friday.
+- +<n><unit>: n days (d), weeks (w), months (m) or years (y) from today, eg +2w.
+Parameters:
+- value: string: the date provided by the user.
+- now: time.Time: the time relative dates are resolved against.
+- endOfRange: bool: true when resolving the end of a date range, eg date-to.
+Returns:
+- time.Time: the resolved date.
+- error: wrapping ErrInvalidDate if the date is not recognised.
+*/
+func ResolveDate(value string, now time.Time, endOfRange bool) (time.Time, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch value {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	case "this-weekend", "next-weekend":
+		// days until saturday, a sunday belongs to the weekend that started the day before
+		days := (int(time.Saturday) - int(today.Weekday()) + 7) % 7
+		if today.Weekday() == time.Sunday {
+			days = -1
+		}
+		if value == "next-weekend" {
+			days += 7
+		}
+		saturday := today.AddDate(0, 0, days)
+		if endOfRange {
+			return saturday.AddDate(0, 0, 1), nil
+		}
+		// the weekend has already started
+		if saturday.Before(today) {
+			return today, nil
+		}
+		return saturday, nil
+	}
+
+	// next-<weekday>
+	if weekdayName, ok := strings.CutPrefix(value, "next-"); ok {
+		weekday, ok := weekdays[weekdayName]
+		if !ok {
+			return time.Time{}, fmt.Errorf("%w: %s unknown weekday %s", ErrInvalidDate, value, weekdayName)
+		}
+		days := (int(weekday) - int(today.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return today.AddDate(0, 0, days), nil
+	}
+
+	// +<n><unit>
+	if offset, ok := strings.CutPrefix(value, "+"); ok && len(offset) > 1 {
+		n, err := strconv.Atoi(offset[:len(offset)-1])
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("%w: %s offset must be a positive number followed by d, w, m or y", ErrInvalidDate, value)
+		}
+		switch offset[len(offset)-1] {
+		case 'd':
+			return today.AddDate(0, 0, n), nil
+		case 'w':
+			return today.AddDate(0, 0, 7*n), nil
+		case 'm':
+			return today.AddDate(0, n, 0), nil
+		case 'y':
+			return today.AddDate(n, 0, 0), nil
+		}
+		return time.Time{}, fmt.Errorf("%w: %s unit must be d, w, m or y", ErrInvalidDate, value)
+	}
+
+	// Check date in correct format
+	date, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s must be YYYY-MM-DD or a relative date like today, this-weekend, next-friday or +2w", ErrInvalidDate, value)
+	}
+	return date, nil
+}
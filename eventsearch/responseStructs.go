@@ -1,73 +1,123 @@
 package eventsearch
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
-	"fmt"
+	"strings"
 	"time"
 )
 
 // general struct to store relevant event details of event returned from API
 type FoundEvent struct {
-	Name     string
-	Date     time.Time
-	City     string
+	Name string
+	Date time.Time
+	// start time of the event on Date, zero if the provider did not give a time
+	Start time.Time
+	// end of the event, zero if the provider did not give one
+	End  time.Time
+	City string
+	// name of the venue, "" if the provider did not give one
+	Venue    string
 	Tickets  string
 	Genre    string
 	Subgenre string
+	Provider string
+	// every listing of the event, more than one when duplicates across providers were merged
+	Sources []EventSource
 }
 
-type UnmarshalFunction func([]byte) ([]FoundEvent, error)
-
-// unmarshalls the ticketmaster API response then returns relevant details of events in []FoundEvent
-func UnmarshalTicketmasterJSON(b []byte) ([]FoundEvent, error) {
-	// unmarshall the response into TicketmasterResponse struct
-	ticketmasterRes := TicketmasterResponse{}
-	if err := json.Unmarshal(b, &ticketmasterRes); err != nil {
-		fmt.Println("Error unmarshaling JSON:", err)
-		return nil, err
-	}
-
+// returns relevant details of the events in a ticketmaster API response in []FoundEvent
+func (r TicketmasterResponse) FoundEvents() []FoundEvent {
 	var foundEvents []FoundEvent
 	// iterate over the events in the response, append a FoundEvent{} containing relevant details to slice
-	for _, event := range ticketmasterRes.Embedded.Events {
+	for _, event := range r.Embedded.Events {
 		date, _ := time.Parse(time.DateOnly, event.Dates.Start.LocalDate)
-		foundEvents = append(foundEvents, FoundEvent{
-			Name:     event.Name,
-			Date:     date,
-			City:     event.Embedded.Venues[0].City.Name,
-			Tickets:  event.URL,
-			Genre:    event.Classifications[0].Segment.Name,
-			Subgenre: event.Classifications[0].Genre.Name,
-		})
+		foundEvent := FoundEvent{
+			Name:    event.Name,
+			Date:    date,
+			Start:   atTime(date, event.Dates.Start.LocalTime),
+			Tickets: event.URL,
+		}
+		// the end is only given for some events, eg festivals
+		if endDate, err := time.Parse(time.DateOnly, event.Dates.End.LocalDate); err == nil {
+			foundEvent.End = atTime(endDate, event.Dates.End.LocalTime)
+		}
+		// venues and classifications are not always present
+		if len(event.Embedded.Venues) > 0 {
+			foundEvent.City = event.Embedded.Venues[0].City.Name
+			foundEvent.Venue = event.Embedded.Venues[0].Name
+		}
+		if len(event.Classifications) > 0 {
+			foundEvent.Genre = event.Classifications[0].Segment.Name
+			foundEvent.Subgenre = event.Classifications[0].Genre.Name
+		}
+		foundEvents = append(foundEvents, foundEvent)
 	}
-
-	return foundEvents, nil
+	return foundEvents
 }
 
-// unmarshalls the skiddle API response then returns relevant details of events in []FoundEvent
-func UnmarshalSkiddleJSON(b []byte) ([]FoundEvent, error) {
-	// unmarshall the response into SkiddleResponse struct
-	skiddleRes := SkiddleResponse{}
-	if err := json.Unmarshal(b, &skiddleRes); err != nil {
-		fmt.Println("Error unmarshaling JSON:", err)
-		return nil, err
-	}
-
+// returns relevant details of the events in a skiddle API response in []FoundEvent
+func (r SkiddleResponse) FoundEvents() []FoundEvent {
 	var foundEvents []FoundEvent
 	// iterate over the events in the response, append a FoundEvent{} containing relevant details to slice
-	for _, event := range skiddleRes.Results {
+	for _, event := range r.Results {
 		date, _ := time.Parse(time.DateOnly, event.Date)
+		start := atTime(date, event.OpeningTimes.DoorsOpen)
+		end := atTime(date, event.OpeningTimes.DoorsClose)
+		// doors closing before they open means they close after midnight
+		if !start.IsZero() && !end.IsZero() && !end.After(start) {
+			end = end.AddDate(0, 0, 1)
+		}
 		foundEvents = append(foundEvents, FoundEvent{
 			Name:    event.EventName,
 			Date:    date,
+			Start:   start,
+			End:     end,
 			City:    event.Venue.Town,
+			Venue:   event.Venue.Name,
 			Tickets: event.Link,
 			Genre:   event.EventCode,
 			//Subgenre: event.Genres[0].Name,
 		})
 	}
+	return foundEvents
+}
 
-	return foundEvents, nil
+/*
+Ref returns a short reference to the event that stays the same across searches, made from the provider, ticket link and date of the listing. It is printed with search results so the event can be added to the calendar with calendar add-from-search.
+*/
+func (e FoundEvent) Ref() string {
+	hash := sha1.Sum([]byte(e.Provider + "|" + e.Tickets + "|" + e.Date.Format(time.DateOnly)))
+	return hex.EncodeToString(hash[:])[:refLength]
+}
+
+// number of hex characters of a ref, enough to tell apart the results of any search
+const refLength = 8
+
+/*
+Fingerprint returns a key for the event made from its normalised name, city and venue, unlike Ref it stays the same when the date or ticket link of the event changes or it is listed by another provider. The nights of a run at the same venue share a fingerprint.
+*/
+func (e FoundEvent) Fingerprint() string {
+	hash := sha1.Sum([]byte(normaliseName(e.Name) + "|" + strings.ToLower(strings.TrimSpace(e.City)) + "|" + normaliseName(e.Venue)))
+	return hex.EncodeToString(hash[:])
+}
+
+/*
+Combines a date with a local time of day in format HH:MM or HH:MM:SS.
+Returns:
+- time.Time: the date at the time, zero if the date or time is missing or invalid.
+*/
+func atTime(date time.Time, clock string) time.Time {
+	if date.IsZero() || clock == "" {
+		return time.Time{}
+	}
+	for _, layout := range []string{time.TimeOnly, "15:04"} {
+		if t, err := time.Parse(layout, clock); err == nil {
+			return time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), t.Second(), 0, date.Location())
+		}
+	}
+	return time.Time{}
 }
 
 // struct to store Ticketmaster API resposne json
@@ -75,6 +125,13 @@ type TicketmasterResponse struct {
 	Embedded struct {
 		Events []TicketmasterEvent `json:"events"`
 	} `json:"_embedded"`
+	// paging metadata, number is the zero based index of the returned page
+	Page struct {
+		Size          int `json:"size"`
+		TotalElements int `json:"totalElements"`
+		TotalPages    int `json:"totalPages"`
+		Number        int `json:"number"`
+	} `json:"page"`
 }
 
 type TicketmasterEvent struct {
@@ -83,10 +140,16 @@ type TicketmasterEvent struct {
 	Dates struct {
 		Start struct {
 			LocalDate string `json:"localDate"`
+			LocalTime string `json:"localTime"`
 		} `json:"start"`
+		End struct {
+			LocalDate string `json:"localDate"`
+			LocalTime string `json:"localTime"`
+		} `json:"end"`
 	} `json:"dates"`
 	Embedded struct {
 		Venues []struct {
+			Name string `json:"name"`
 			City struct {
 				Name string `json:"name"`
 			} `json:"city"`
@@ -104,14 +167,21 @@ type TicketmasterEvent struct {
 
 // struct to store skiddle API resposne json
 type SkiddleResponse struct {
-	Results []struct {
+	// total number of results across all pages, skiddle sends it as a quoted string
+	TotalCount json.Number `json:"totalcount"`
+	Results    []struct {
 		EventCode string `json:"EventCode"`
 		EventName string `json:"eventname"`
 		Venue     struct {
+			Name string `json:"name"`
 			Town string `json:"town"`
 		} `json:"venue"`
-		Link   string `json:"link"`
-		Date   string `json:"date"`
+		Link         string `json:"link"`
+		Date         string `json:"date"`
+		OpeningTimes struct {
+			DoorsOpen  string `json:"doorsopen"`
+			DoorsClose string `json:"doorsclose"`
+		} `json:"openingtimes"`
 		Genres []struct {
 			Name string `json:"name"`
 		} `json:"genres"`
@@ -124,9 +194,12 @@ type GenreJSON struct {
 		Genres []string `json:"Genres"`
 	} `json:"Ticketmaster"`
 	Skiddle struct {
-		Genres []struct {
-			Name string `json:"Name"`
-			ID   string `json:"ID"`
-		} `json:"Genres"`
+		Genres []SkiddleGenre `json:"Genres"`
 	} `json:"Skiddle"`
 }
+
+// a skiddle genre from genres.json, skiddle requests filter by the ID
+type SkiddleGenre struct {
+	Name string `json:"Name"`
+	ID   string `json:"ID"`
+}
@@ -0,0 +1,35 @@
+package eventsearch
+
+import (
+	"time"
+
+	"github.com/codingsince1985/geo-golang"
+)
+
+// SearchResult holds everything a search produced. The eventsearch package never writes to stdout, the caller decides what to display.
+type SearchResult struct {
+	Events []FoundEvent
+	// report of the requests, timings and errors of each selected provider
+	Providers []ProviderReport
+	// the provider genres matched to the user genres, keyed by provider name
+	Genres map[string][]string
+	// the cities that were geocoded for providers that search around a location
+	Locations []CityLocation
+	// problems that did not stop the search, eg a city that could not be geocoded
+	Warnings []error
+	// total time taken by the search
+	Duration time.Duration
+}
+
+// CityLocation is a user provided city and the location it was geocoded to.
+type CityLocation struct {
+	City     string
+	Location geo.Location
+}
+
+/*
+GenreMatcher is implemented by providers that map the user genres onto the provider's own genres, the matches are reported in SearchResult.Genres.
+*/
+type GenreMatcher interface {
+	MatchGenres(genres []string) ([]string, error)
+}
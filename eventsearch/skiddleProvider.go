@@ -0,0 +1,154 @@
+package eventsearch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hbollon/go-edlib"
+)
+
+// maximum number of results skiddle returns per page
+const skiddlePageSize = 100
+
+// SkiddleProvider searches the skiddle API, which searches around a point so one request is made per geocoded city.
+type SkiddleProvider struct{}
+
+func init() {
+	RegisterProvider(SkiddleProvider{})
+}
+
+func (SkiddleProvider) Name() string {
+	return "skiddle"
+}
+
+/*
+Creates a skiddle request url for each geocoded city with the search parameters.
+Returns:
+- []string: urls to be used in API requests with relevant query parameters.
+- error: if the genres file could not be read or no cities were geocoded.
+*/
+func (p SkiddleProvider) BuildRequests(params SearchParams) ([]string, error) {
+	if len(params.Locations) == 0 {
+		return nil, errors.New("no geocoded cities to search around")
+	}
+	// genres are only matched when the user gave some
+	var genres []SkiddleGenre
+	if len(params.Genres) > 0 {
+		var err error
+		genres, err = p.matchGenres(params.Genres)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var genreIDs []string
+	for _, genre := range genres {
+		genreIDs = append(genreIDs, genre.ID)
+	}
+	genreID := strings.Join(genreIDs, ",")
+	apiKey := os.Getenv("skiddleAPIKey")
+	var requestUrls []string
+	for _, location := range params.Locations {
+		// set base skiddle url
+		requestUrl := fmt.Sprintf("https://www.skiddle.com/api/v1/events/search/?api_key=%s", apiKey)
+		// set query params for api request
+		requestUrl += fmt.Sprintf("&longitude=%s", url.QueryEscape(fmt.Sprintf("%f", location.Lng)))
+		requestUrl += fmt.Sprintf("&latitude=%s", url.QueryEscape(fmt.Sprintf("%f", location.Lat)))
+		requestUrl += fmt.Sprintf("&radius=%s", url.QueryEscape("8"))
+		requestUrl += fmt.Sprintf("&minDate=%s", url.QueryEscape(params.DateFrom.Format(time.DateOnly)))
+		requestUrl += fmt.Sprintf("&maxDate=%s", url.QueryEscape(params.DateTo.Format(time.DateOnly)))
+		requestUrl += fmt.Sprintf("&description=%s", url.QueryEscape("1"))
+		if genreID != "" {
+			requestUrl += fmt.Sprintf("&g=%s", url.QueryEscape(genreID))
+		}
+		requestUrl += fmt.Sprintf("&limit=%d", skiddlePageSize)
+		requestUrl += fmt.Sprintf("&offset=%d", 0)
+		requestUrls = append(requestUrls, requestUrl)
+	}
+	return requestUrls, nil
+}
+
+/*
+Unmarshalls a skiddle response into []FoundEvent and uses the total count to build the url of the next page.
+Returns:
+- []FoundEvent: the events on the page.
+- string: the url of the next page, "" if this is the last page.
+*/
+func (SkiddleProvider) ParseResponse(requestUrl string, body []byte) ([]FoundEvent, string, error) {
+	skiddleRes := SkiddleResponse{}
+	if err := json.Unmarshal(body, &skiddleRes); err != nil {
+		return nil, "", err
+	}
+	events := skiddleRes.FoundEvents()
+
+	// find the offset of the next page from the offset of this one
+	parsedUrl, err := url.Parse(requestUrl)
+	if err != nil {
+		return events, "", err
+	}
+	offset, _ := strconv.Atoi(parsedUrl.Query().Get("offset"))
+	nextOffset := offset + len(skiddleRes.Results)
+	totalCount, _ := skiddleRes.TotalCount.Int64()
+	// stop when a page is empty or every result has been returned
+	if len(skiddleRes.Results) == 0 || int64(nextOffset) >= totalCount {
+		return events, "", nil
+	}
+	nextUrl, err := setQueryParam(requestUrl, "offset", nextOffset)
+	if err != nil {
+		return events, "", err
+	}
+	return events, nextUrl, nil
+}
+
+/*
+Returns the name of the skiddle genre that best matches each user genre.
+*/
+func (p SkiddleProvider) MatchGenres(userGenres []string) ([]string, error) {
+	genres, err := p.matchGenres(userGenres)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, genre := range genres {
+		names = append(names, genre.Name)
+	}
+	return names, nil
+}
+
+/*
+Uses the levenshtien algorithm to find the skiddle genre that best matches each user genre, skiddle requires the genre ID.
+Returns:
+- []SkiddleGenre: the matched skiddle genre for each user genre.
+*/
+func (SkiddleProvider) matchGenres(userGenres []string) ([]SkiddleGenre, error) {
+	genres, err := loadGenres()
+	if err != nil {
+		return nil, err
+	}
+	var skiddleGenres []SkiddleGenre
+	for _, userGenre := range userGenres {
+		var stringSimilarity float32
+		var bestMatch SkiddleGenre
+		// find the skiddle genre that matches user input closest
+		for _, skiddleGenre := range genres.Skiddle.Genres {
+			similarityRes, _ := edlib.StringsSimilarity(userGenre, skiddleGenre.Name, edlib.Levenshtein)
+			// if strings match exactly set best match break loop
+			if similarityRes == 1 {
+				bestMatch = skiddleGenre
+				break
+			}
+			// current best match
+			if similarityRes > stringSimilarity {
+				stringSimilarity = similarityRes
+				bestMatch = skiddleGenre
+			}
+		}
+		skiddleGenres = append(skiddleGenres, bestMatch)
+	}
+	return skiddleGenres, nil
+}
@@ -0,0 +1,104 @@
+package eventsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hbollon/go-edlib"
+)
+
+// ticketmaster page size and the deepest result the discovery API will page to
+const (
+	ticketmasterPageSize = 100
+	ticketmasterMaxDepth = 1000
+)
+
+// TicketmasterProvider searches the ticketmaster discovery API, which accepts the list of cities in a single request.
+type TicketmasterProvider struct{}
+
+func init() {
+	RegisterProvider(TicketmasterProvider{})
+}
+
+func (TicketmasterProvider) Name() string {
+	return "ticketmaster"
+}
+
+/*
+Creates the ticketmaster request url with the search parameters.
+Returns:
+- []string: a single url to be used in a API request with relevant query parameters.
+- error: if the genres file could not be read.
+*/
+func (p TicketmasterProvider) BuildRequests(params SearchParams) ([]string, error) {
+	// genres are only matched when the user gave some
+	var genres []string
+	if len(params.Genres) > 0 {
+		var err error
+		genres, err = p.MatchGenres(params.Genres)
+		if err != nil {
+			return nil, err
+		}
+	}
+	apiKey := os.Getenv("ticketmasterAPIKey")
+	// set base ticketmaster url
+	requestUrl := fmt.Sprintf("https://app.ticketmaster.com/discovery/v2/events.json?apikey=%s", apiKey)
+	// set query params for api request, the end date is inclusive so search to the end of the day
+	requestUrl += fmt.Sprintf("&city=%s", url.QueryEscape(strings.Join(params.Cities, ",")))
+	requestUrl += fmt.Sprintf("&classificationName=%s", url.QueryEscape(strings.Join(genres, ",")))
+	requestUrl += fmt.Sprintf("&startDateTime=%s", url.QueryEscape(params.DateFrom.Format(time.RFC3339)))
+	requestUrl += fmt.Sprintf("&endDateTime=%s", url.QueryEscape(params.DateTo.Add(24*time.Hour-time.Second).Format(time.RFC3339)))
+	requestUrl += fmt.Sprintf("&size=%d", ticketmasterPageSize)
+	requestUrl += fmt.Sprintf("&page=%d", 0)
+
+	return []string{requestUrl}, nil
+}
+
+/*
+Unmarshalls a ticketmaster response into []FoundEvent and uses the page metadata to build the url of the next page.
+Returns:
+- []FoundEvent: the events on the page.
+- string: the url of the next page, "" if this is the last page.
+*/
+func (TicketmasterProvider) ParseResponse(requestUrl string, body []byte) ([]FoundEvent, string, error) {
+	ticketmasterRes := TicketmasterResponse{}
+	if err := json.Unmarshal(body, &ticketmasterRes); err != nil {
+		return nil, "", err
+	}
+	events := ticketmasterRes.FoundEvents()
+
+	page := ticketmasterRes.Page
+	nextPage := page.Number + 1
+	// stop at the last page, ticketmaster also refuses deep paging past the 1000th result
+	if nextPage >= page.TotalPages || nextPage*page.Size >= ticketmasterMaxDepth {
+		return events, "", nil
+	}
+	nextUrl, err := setQueryParam(requestUrl, "page", nextPage)
+	if err != nil {
+		return events, "", err
+	}
+	return events, nextUrl, nil
+}
+
+/*
+Uses the levenshtien algorithm to find the ticketmaster genre that best matches each user genre, ticketmaster requires spelling + wording to be the same as exspected.
+Returns:
+- []string: the matched ticketmaster genre for each user genre.
+*/
+func (TicketmasterProvider) MatchGenres(userGenres []string) ([]string, error) {
+	genres, err := loadGenres()
+	if err != nil {
+		return nil, err
+	}
+	var ticketmasterGenres []string
+	for _, userGenre := range userGenres {
+		// find the ticketmaster genre that matches user input closest
+		bestMatchTicketmasterGenre, _ := edlib.FuzzySearch(userGenre, genres.Ticketmaster.Genres, edlib.Levenshtein)
+		ticketmasterGenres = append(ticketmasterGenres, bestMatchTicketmasterGenre)
+	}
+	return ticketmasterGenres, nil
+}
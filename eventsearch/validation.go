@@ -0,0 +1,130 @@
+package eventsearch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hbollon/go-edlib"
+)
+
+// errors returned by Validate, wrapped with the offending value so they can be checked with errors.Is
+var (
+	ErrInvalidDate      = errors.New("invalid date")
+	ErrDateInPast       = errors.New("date-from is in the past")
+	ErrInvalidDateRange = errors.New("date-from is after date-to")
+	ErrNoCities         = errors.New("no cities provided")
+	ErrUnknownGenre     = errors.New("unknown genre")
+	ErrNoProviders      = errors.New("no providers selected")
+	ErrUnknownProvider  = errors.New("unknown provider")
+)
+
+// minimum levenshtein similarity between a user genre and a known genre for the genre to be recognised
+const genreSimilarityThreshold = 0.6
+
+/*
+Validates the cities, genres, dates and providers of the search before any network call is made. Dates are resolved with ResolveDate so relative dates are accepted.
+Returns:
+- error: every problem found joined together, each wrapping one of the Err* values.
+*/
+func (s *ApiSearch) Validate() error {
+	var errs []error
+	if len(SplitList(s.Cities)) == 0 {
+		errs = append(errs, ErrNoCities)
+	}
+	if err := s.validateDates(time.Now()); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateGenres(SplitList(s.Genres)); err != nil {
+		errs = append(errs, err)
+	}
+	if err := s.validateProviders(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+/*
+Resolves the user provided dateFrom and DateTo strings to dates, checks they are not in the past and are in order, then sets the dates to be used as query params in the API requests.
+Parameters:
+- now: time.Time: the time relative dates are resolved against.
+*/
+func (s *ApiSearch) validateDates(now time.Time) error {
+	dateFrom, err := ResolveDate(s.DateFrom, now, false)
+	if err != nil {
+		return fmt.Errorf("date-from: %w", err)
+	}
+	dateTo, err := ResolveDate(s.DateTo, now, true)
+	if err != nil {
+		return fmt.Errorf("date-to: %w", err)
+	}
+
+	// Check if dateFrom is later than the current date
+	today, _ := ResolveDate("today", now, false)
+	if dateFrom.Before(today) {
+		return fmt.Errorf("%w: %s", ErrDateInPast, dateFrom.Format(time.DateOnly))
+	}
+	// Check if dateFrom is earlier than dateTo
+	if dateFrom.After(dateTo) {
+		return fmt.Errorf("%w: %s is after %s", ErrInvalidDateRange, dateFrom.Format(time.DateOnly), dateTo.Format(time.DateOnly))
+	}
+
+	s.dateFrom = dateFrom
+	s.dateTo = dateTo
+	return nil
+}
+
+/*
+Checks each user genre is close enough to a ticketmaster or skiddle genre in genres.json to be matched, suggesting the closest genre when it is not.
+*/
+func validateGenres(userGenres []string) error {
+	if len(userGenres) == 0 {
+		return nil
+	}
+	genres, err := loadGenres()
+	if err != nil {
+		return fmt.Errorf("reading genres: %w", err)
+	}
+	knownGenres := append([]string{}, genres.Ticketmaster.Genres...)
+	for _, skiddleGenre := range genres.Skiddle.Genres {
+		knownGenres = append(knownGenres, skiddleGenre.Name)
+	}
+
+	var errs []error
+	for _, userGenre := range userGenres {
+		var bestSimilarity float32
+		var bestMatch string
+		for _, knownGenre := range knownGenres {
+			similarity, _ := edlib.StringsSimilarity(strings.ToLower(userGenre), strings.ToLower(knownGenre), edlib.Levenshtein)
+			if similarity > bestSimilarity {
+				bestSimilarity = similarity
+				bestMatch = knownGenre
+			}
+		}
+		if bestSimilarity < genreSimilarityThreshold {
+			errs = append(errs, fmt.Errorf("%w: %s, closest genre is %s", ErrUnknownGenre, userGenre, bestMatch))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+/*
+Checks at least one provider is selected and every named provider is registered.
+*/
+func (s *ApiSearch) validateProviders() error {
+	names := s.Providers
+	if len(names) == 0 {
+		names = ProviderNames()
+	}
+	if len(names) == 0 {
+		return ErrNoProviders
+	}
+	var errs []error
+	for _, name := range names {
+		if _, ok := GetProvider(name); !ok {
+			errs = append(errs, fmt.Errorf("%w: %s, available providers are %s", ErrUnknownProvider, name, strings.Join(ProviderNames(), ", ")))
+		}
+	}
+	return errors.Join(errs...)
+}
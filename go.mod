@@ -6,6 +6,8 @@ require (
 	github.com/codingsince1985/geo-golang v1.8.3
 	github.com/hbollon/go-edlib v1.6.0
 	github.com/joho/godotenv v1.5.1
+	golang.org/x/term v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
 	modernc.org/sqlite v1.27.0
 )
 
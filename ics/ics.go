@@ -0,0 +1,140 @@
+package ics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// formats of DATE and floating DATE-TIME values, times are written without a zone so they are read as the local time of the event
+const (
+	dateFormat     = "20060102"
+	dateTimeFormat = "20060102T150405"
+)
+
+// maximum length in octets of a content line before it is folded
+const maxLineLength = 75
+
+// Event is a VEVENT of an RFC 5545 iCalendar file.
+type Event struct {
+	UID     string
+	Summary string
+	Start   time.Time
+	// exclusive end of the event, zero if the event has no end
+	End time.Time
+	// all day events are written as DATE values and only the date of Start and End is used
+	AllDay      bool
+	Location    string
+	URL         string
+	Description string
+	// recurrence rule in RFC 5545 form without the RRULE: prefix, eg FREQ=WEEKLY;BYDAY=TU
+	RRule string
+	// dates a repeating event does not happen on, written as EXDATE at the time of day of Start
+	ExDates []time.Time
+	// start of the occurrence of a repeating event with the same UID that this event replaces, zero unless the event is an override
+	RecurrenceID time.Time
+}
+
+/*
+Write writes the events as an iCalendar VCALENDAR to w, escaping text values and folding long lines.
+Parameters:
+- w: io.Writer: where to write the calendar.
+- events: []Event: the events to write as VEVENTs.
+Returns:
+- error: from writing to w.
+*/
+func Write(w io.Writer, events []Event) error {
+	writer := bufio.NewWriter(w)
+	writeLine(writer, "BEGIN:VCALENDAR")
+	writeLine(writer, "VERSION:2.0")
+	writeLine(writer, "PRODID:-//go_events_cli//EN")
+	writeLine(writer, "CALSCALE:GREGORIAN")
+	stamp := time.Now().UTC().Format(dateTimeFormat) + "Z"
+	for _, event := range events {
+		writeLine(writer, "BEGIN:VEVENT")
+		writeLine(writer, "UID:"+escapeText(event.UID))
+		writeLine(writer, "DTSTAMP:"+stamp)
+		if event.AllDay {
+			writeLine(writer, "DTSTART;VALUE=DATE:"+event.Start.Format(dateFormat))
+			end := event.End
+			// an all day event without an end lasts the day it starts on
+			if end.IsZero() {
+				end = event.Start.AddDate(0, 0, 1)
+			}
+			writeLine(writer, "DTEND;VALUE=DATE:"+end.Format(dateFormat))
+		} else {
+			writeLine(writer, "DTSTART:"+event.Start.Format(dateTimeFormat))
+			if !event.End.IsZero() {
+				writeLine(writer, "DTEND:"+event.End.Format(dateTimeFormat))
+			}
+		}
+		writeLine(writer, "SUMMARY:"+escapeText(event.Summary))
+		if event.Location != "" {
+			writeLine(writer, "LOCATION:"+escapeText(event.Location))
+		}
+		if event.URL != "" {
+			writeLine(writer, "URL:"+event.URL)
+		}
+		if event.Description != "" {
+			writeLine(writer, "DESCRIPTION:"+escapeText(event.Description))
+		}
+		if event.RRule != "" {
+			writeLine(writer, "RRULE:"+event.RRule)
+		}
+		if len(event.ExDates) > 0 {
+			writeLine(writer, exDateLine(event))
+		}
+		if !event.RecurrenceID.IsZero() && event.AllDay {
+			writeLine(writer, "RECURRENCE-ID;VALUE=DATE:"+event.RecurrenceID.Format(dateFormat))
+		} else if !event.RecurrenceID.IsZero() {
+			writeLine(writer, "RECURRENCE-ID:"+event.RecurrenceID.Format(dateTimeFormat))
+		}
+		writeLine(writer, "END:VEVENT")
+	}
+	writeLine(writer, "END:VCALENDAR")
+	return writer.Flush()
+}
+
+// the EXDATE line of an event, with DATE values for an all day event and DATE-TIME values at the start time otherwise
+func exDateLine(event Event) string {
+	var values []string
+	for _, date := range event.ExDates {
+		if event.AllDay {
+			values = append(values, date.Format(dateFormat))
+			continue
+		}
+		at := time.Date(date.Year(), date.Month(), date.Day(), event.Start.Hour(), event.Start.Minute(), event.Start.Second(), 0, time.UTC)
+		values = append(values, at.Format(dateTimeFormat))
+	}
+	if event.AllDay {
+		return "EXDATE;VALUE=DATE:" + strings.Join(values, ",")
+	}
+	return "EXDATE:" + strings.Join(values, ",")
+}
+
+// writes a content line ending in CRLF, folding it onto continuation lines starting with a space when it is too long
+func writeLine(writer *bufio.Writer, line string) {
+	for len(line) > maxLineLength {
+		// fold on a rune boundary so multi byte characters are not split
+		cut := maxLineLength
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		fmt.Fprintf(writer, "%s\r\n", line[:cut])
+		line = " " + line[cut:]
+	}
+	fmt.Fprintf(writer, "%s\r\n", line)
+}
+
+// reports whether b is the first byte of a utf-8 encoded rune
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// escapes the characters that have special meaning in TEXT values
+func escapeText(text string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return replacer.Replace(text)
+}
@@ -0,0 +1,296 @@
+package ics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// a property of a content line, eg DTSTART;TZID=Europe/London:20231105T193000
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+/*
+Parse reads the VEVENTs of an RFC 5545 iCalendar file. Folded lines are unfolded and text values unescaped, the properties of components inside a VEVENT such as VALARM are ignored.
+DATE values are read as all day events, DATE-TIME values with a TZID are read as the wall clock time in that zone, UTC values ending in Z are converted to the local time and floating values are kept as they are. When a VEVENT has a DURATION instead of a DTEND the end is the start plus the duration.
+Parameters:
+- r: io.Reader: the iCalendar file.
+Returns:
+- []Event: the events in the order they appear.
+- error: if reading fails, a VEVENT is not closed or has an invalid DTSTART.
+*/
+func Parse(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	var event *Event
+	var duration string
+	// names of the components open at each line, innermost last, so the properties of a VALARM in a VEVENT are not read as the event's
+	var open []string
+	for i, line := range lines {
+		prop, ok := parseProperty(line)
+		if !ok {
+			continue
+		}
+		switch prop.name {
+		case "BEGIN":
+			component := strings.ToUpper(prop.value)
+			open = append(open, component)
+			if component == "VEVENT" {
+				event = &Event{}
+				duration = ""
+			}
+		case "END":
+			component := strings.ToUpper(prop.value)
+			// close the innermost component with the name, and any left open inside it
+			for j := len(open) - 1; j >= 0; j-- {
+				if open[j] == component {
+					open = open[:j]
+					break
+				}
+			}
+			if component != "VEVENT" || event == nil {
+				continue
+			}
+			if event.Start.IsZero() {
+				return nil, fmt.Errorf("line %d: event %q has no DTSTART", i+1, event.Summary)
+			}
+			if event.End.IsZero() && duration != "" {
+				d, err := parseDuration(duration)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", i+1, err)
+				}
+				event.End = event.Start.Add(d)
+			}
+			events = append(events, *event)
+			event = nil
+		default:
+			// properties of the calendar or of other components such as VTIMEZONE, or a VALARM inside the event
+			if event == nil || len(open) == 0 || open[len(open)-1] != "VEVENT" {
+				continue
+			}
+			if err := setProperty(event, prop, &duration); err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+		}
+	}
+	if event != nil {
+		return nil, fmt.Errorf("event %q is missing END:VEVENT", event.Summary)
+	}
+	return events, nil
+}
+
+/*
+ApplyOverrides splits the overrides of single occurrences out of their repeating events. An override is a VEVENT with the UID of a repeating event and a RECURRENCE-ID giving the start of the occurrence it replaces, stored as it is it would replace the whole series. The overridden occurrence is added to the except dates of its series and the override becomes an event of its own, with a UID made from the series UID and the RECURRENCE-ID so importing the file again matches it.
+Parameters:
+- events: []Event: the events read by Parse.
+Returns:
+- []Event: the events in the same order, none of them an override.
+*/
+func ApplyOverrides(events []Event) []Event {
+	result := append([]Event(nil), events...)
+	// index of the repeating event of each uid
+	series := make(map[string]int)
+	for i, event := range result {
+		if event.RecurrenceID.IsZero() && event.RRule != "" {
+			series[event.UID] = i
+		}
+	}
+	for i, event := range result {
+		if event.RecurrenceID.IsZero() {
+			continue
+		}
+		if j, ok := series[event.UID]; ok && !containsTime(result[j].ExDates, event.RecurrenceID) {
+			// a full slice expression so the except dates of the events passed in are not changed
+			exDates := result[j].ExDates
+			result[j].ExDates = append(exDates[:len(exDates):len(exDates)], event.RecurrenceID)
+		}
+		layout := dateTimeFormat
+		if event.AllDay {
+			layout = dateFormat
+		}
+		result[i].UID = event.UID + "-" + event.RecurrenceID.Format(layout)
+		result[i].RRule = ""
+		result[i].RecurrenceID = time.Time{}
+	}
+	return result
+}
+
+// reports whether times contains t
+func containsTime(times []time.Time, t time.Time) bool {
+	for _, other := range times {
+		if other.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// sets the field of the event for a property, properties that are not supported are ignored
+func setProperty(event *Event, prop property, duration *string) error {
+	var err error
+	switch prop.name {
+	case "UID":
+		event.UID = unescapeText(prop.value)
+	case "SUMMARY":
+		event.Summary = unescapeText(prop.value)
+	case "LOCATION":
+		event.Location = unescapeText(prop.value)
+	case "DESCRIPTION":
+		event.Description = unescapeText(prop.value)
+	case "URL":
+		event.URL = prop.value
+	case "RRULE":
+		event.RRule = prop.value
+	case "EXDATE":
+		// one or more dates seperated by commas, an event can have several EXDATE lines
+		for _, value := range strings.Split(prop.value, ",") {
+			var exDate time.Time
+			exDate, _, err = parseTime(property{name: prop.name, params: prop.params, value: value})
+			if err != nil {
+				break
+			}
+			event.ExDates = append(event.ExDates, exDate)
+		}
+	case "DTSTART":
+		event.Start, event.AllDay, err = parseTime(prop)
+	case "DTEND":
+		event.End, _, err = parseTime(prop)
+	case "DURATION":
+		*duration = prop.value
+	case "RECURRENCE-ID":
+		event.RecurrenceID, _, err = parseTime(prop)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %s: %v", prop.name, prop.value, err)
+	}
+	return nil
+}
+
+/*
+Reads the content lines of a file, joining folded lines that continue with a space or tab onto the line before.
+*/
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	// descriptions can make very long unfolded lines
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+/*
+Splits a content line into its name, parameters and value.
+Returns:
+- property: the property with an upper case name and parameter names.
+- bool: false if the line is not a property, eg a blank line.
+*/
+func parseProperty(line string) (property, bool) {
+	// the value starts at the first colon that is not in a quoted parameter value
+	quoted := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon <= 0 {
+		return property{}, false
+	}
+	prop := property{params: map[string]string{}, value: line[colon+1:]}
+	parts := strings.Split(line[:colon], ";")
+	prop.name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		key, value, _ := strings.Cut(param, "=")
+		prop.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+	return prop, true
+}
+
+/*
+Parses a DTSTART or DTEND value.
+Returns:
+- time.Time: the wall clock time of the value in UTC so it can be compared with the floating times of the calendar.
+- bool: true if the value is a DATE.
+*/
+func parseTime(prop property) (time.Time, bool, error) {
+	value := prop.value
+	if strings.EqualFold(prop.params["VALUE"], "DATE") || len(value) == len(dateFormat) {
+		t, err := time.Parse(dateFormat, value)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(dateTimeFormat+"Z", value)
+		if err != nil {
+			return t, false, err
+		}
+		return floating(t.In(time.Local)), false, nil
+	}
+	location := time.UTC
+	if tzid := prop.params["TZID"]; tzid != "" {
+		loaded, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("unknown time zone %s", tzid)
+		}
+		location = loaded
+	}
+	t, err := time.ParseInLocation(dateTimeFormat, value, location)
+	if err != nil {
+		return t, false, err
+	}
+	return floating(t), false, nil
+}
+
+// the wall clock time of t as a time in UTC
+func floating(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// matches an RFC 5545 duration, eg PT2H30M, P1D or P2W
+var durationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parses a DURATION value
+func parseDuration(value string) (time.Duration, error) {
+	match := durationPattern.FindStringSubmatch(value)
+	if match == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("invalid DURATION %s", value)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if match[i+2] == "" {
+			continue
+		}
+		n, _ := strconv.Atoi(match[i+2])
+		d += time.Duration(n) * unit
+	}
+	if match[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+// reverses escapeText
+func unescapeText(text string) string {
+	replacer := strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+	return replacer.Replace(text)
+}
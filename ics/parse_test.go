@@ -0,0 +1,192 @@
+package ics
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// an iCalendar file of the content lines, ending each line in CRLF as Write does
+func calendar(lines ...string) string {
+	lines = append(append([]string{"BEGIN:VCALENDAR", "VERSION:2.0"}, lines...), "END:VCALENDAR")
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+func TestParse(t *testing.T) {
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2026, 11, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		file string
+		want []Event
+		// text the error must contain, "" if the file is valid
+		err string
+	}{
+		{
+			name: "alarm properties are not the event's",
+			file: calendar(
+				"BEGIN:VEVENT", "UID:gig@example.com", "SUMMARY:Gig", "DESCRIPTION:Doors at 7",
+				"DTSTART:20261105T193000", "DURATION:PT2H",
+				"BEGIN:VALARM", "ACTION:DISPLAY", "SUMMARY:Alarm notification", "DESCRIPTION:This is an event reminder", "TRIGGER:-PT30M", "DURATION:PT15M", "REPEAT:2", "END:VALARM",
+				"LOCATION:O2", "END:VEVENT",
+			),
+			want: []Event{{UID: "gig@example.com", Summary: "Gig", Description: "Doors at 7", Location: "O2", Start: at(5, 19, 30), End: at(5, 21, 30)}},
+		},
+		{
+			name: "time zone definitions are skipped",
+			file: calendar(
+				"BEGIN:VTIMEZONE", "TZID:Europe/London",
+				"BEGIN:STANDARD", "DTSTART:19701025T020000", "TZNAME:GMT", "END:STANDARD",
+				"BEGIN:DAYLIGHT", "DTSTART:19700329T010000", "TZNAME:BST", "END:DAYLIGHT",
+				"END:VTIMEZONE",
+				"BEGIN:VEVENT", "UID:play@example.com", "SUMMARY:Play", "DTSTART;TZID=Europe/London:20261106T143000", "END:VEVENT",
+			),
+			want: []Event{{UID: "play@example.com", Summary: "Play", Start: at(6, 14, 30)}},
+		},
+		{
+			name: "tzid keeps the wall clock time of the zone",
+			file: calendar("BEGIN:VEVENT", "UID:call@example.com", "SUMMARY:Call", "DTSTART;TZID=America/New_York:20261105T090000", "DTEND;TZID=America/New_York:20261105T100000", "END:VEVENT"),
+			want: []Event{{UID: "call@example.com", Summary: "Call", Start: at(5, 9, 0), End: at(5, 10, 0)}},
+		},
+		{
+			name: "duration gives the end",
+			file: calendar("BEGIN:VEVENT", "UID:fair@example.com", "SUMMARY:Fair", "DTSTART:20261107T100000", "DURATION:P1DT2H", "END:VEVENT"),
+			want: []Event{{UID: "fair@example.com", Summary: "Fair", Start: at(7, 10, 0), End: at(8, 12, 0)}},
+		},
+		{
+			name: "dtend wins over duration",
+			file: calendar("BEGIN:VEVENT", "UID:fair@example.com", "SUMMARY:Fair", "DTSTART:20261107T100000", "DTEND:20261107T110000", "DURATION:PT5H", "END:VEVENT"),
+			want: []Event{{UID: "fair@example.com", Summary: "Fair", Start: at(7, 10, 0), End: at(7, 11, 0)}},
+		},
+		{
+			name: "date values are all day",
+			file: calendar("BEGIN:VEVENT", "UID:holiday@example.com", "SUMMARY:Holiday", "DTSTART;VALUE=DATE:20261110", "DTEND;VALUE=DATE:20261112", "END:VEVENT"),
+			want: []Event{{UID: "holiday@example.com", Summary: "Holiday", Start: at(10, 0, 0), End: at(12, 0, 0), AllDay: true}},
+		},
+		{
+			name: "folded and escaped text",
+			file: calendar("BEGIN:VEVENT", "UID:quiz@example.com", "SUMMARY:Quiz\\, round", " s and prizes", "DTSTART:20261109T200000", "RRULE:FREQ=WEEKLY;COUNT=3", "EXDATE:20261116T200000", "END:VEVENT"),
+			want: []Event{{UID: "quiz@example.com", Summary: "Quiz, rounds and prizes", Start: at(9, 20, 0), RRule: "FREQ=WEEKLY;COUNT=3", ExDates: []time.Time{at(16, 20, 0)}}},
+		},
+		{
+			name: "override of an occurrence",
+			file: calendar("BEGIN:VEVENT", "UID:quiz@example.com", "SUMMARY:Quiz (moved)", "RECURRENCE-ID:20261116T200000", "DTSTART:20261117T200000", "END:VEVENT"),
+			want: []Event{{UID: "quiz@example.com", Summary: "Quiz (moved)", Start: at(17, 20, 0), RecurrenceID: at(16, 20, 0)}},
+		},
+		{
+			name: "invalid duration",
+			file: calendar("BEGIN:VEVENT", "SUMMARY:Fair", "DTSTART:20261107T100000", "DURATION:2 hours", "END:VEVENT"),
+			err:  "invalid DURATION",
+		},
+		{
+			name: "unknown time zone",
+			file: calendar("BEGIN:VEVENT", "SUMMARY:Call", "DTSTART;TZID=Nowhere/Town:20261105T090000", "END:VEVENT"),
+			err:  "unknown time zone",
+		},
+		{
+			name: "no start",
+			file: calendar("BEGIN:VEVENT", "SUMMARY:Gig", "END:VEVENT"),
+			err:  "has no DTSTART",
+		},
+		{
+			name: "event not closed",
+			file: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nSUMMARY:Gig\r\nDTSTART:20261105T193000\r\n",
+			err:  "missing END:VEVENT",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events, err := Parse(strings.NewReader(test.file))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("Parse error = %v, want one containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(events, test.want) {
+				t.Errorf("events = %+v\nwant %+v", events, test.want)
+			}
+		})
+	}
+}
+
+func TestWriteParseRoundTrip(t *testing.T) {
+	events := []Event{
+		{
+			UID: "gig@go_events_cli", Summary: "Rock, Paper; Scissors \\ Tour", Location: "O2",
+			Start: time.Date(2026, 11, 5, 19, 30, 0, 0, time.UTC), End: time.Date(2026, 11, 5, 23, 0, 0, 0, time.UTC),
+			URL: "https://example.com/tickets?id=1", Description: "Doors at 7\nNo re-entry. " + strings.Repeat("A long line that has to be folded ", 4),
+		},
+		{
+			UID: "holiday@go_events_cli", Summary: "Holiday", AllDay: true,
+			Start: time.Date(2026, 11, 10, 0, 0, 0, 0, time.UTC), End: time.Date(2026, 11, 12, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			UID: "standup@go_events_cli", Summary: "Standup",
+			Start: time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC), End: time.Date(2026, 10, 20, 9, 30, 0, 0, time.UTC),
+			RRule: "FREQ=WEEKLY;BYDAY=TU;UNTIL=20270601T235959", ExDates: []time.Time{time.Date(2026, 12, 22, 9, 0, 0, 0, time.UTC)},
+		},
+		{
+			UID: "standup@go_events_cli", Summary: "Standup (late)",
+			Start: time.Date(2026, 10, 27, 10, 0, 0, 0, time.UTC), RecurrenceID: time.Date(2026, 10, 27, 9, 0, 0, 0, time.UTC),
+		},
+	}
+	var file bytes.Buffer
+	if err := Write(&file, events); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(&file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, events) {
+		t.Errorf("parsed events = %+v\nwant %+v", parsed, events)
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	file := calendar(
+		"BEGIN:VEVENT", "UID:quiz@example.com", "SUMMARY:Quiz (moved)", "RECURRENCE-ID:20261116T200000", "DTSTART:20261117T200000", "DTEND:20261117T220000", "END:VEVENT",
+		"BEGIN:VEVENT", "UID:quiz@example.com", "SUMMARY:Quiz", "DTSTART:20261109T200000", "DTEND:20261109T220000", "RRULE:FREQ=WEEKLY;COUNT=4", "EXDATE:20261123T200000", "END:VEVENT",
+		"BEGIN:VEVENT", "UID:fair@example.com", "SUMMARY:Fair (late)", "RECURRENCE-ID;VALUE=DATE:20261201", "DTSTART;VALUE=DATE:20261202", "END:VEVENT",
+	)
+	events, err := Parse(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied := ApplyOverrides(events)
+	if len(applied) != 3 {
+		t.Fatalf("got %d events, want 3", len(applied))
+	}
+	override, series, lone := applied[0], applied[1], applied[2]
+
+	// the series keeps its rule and no longer has the overridden occurrence
+	if series.UID != "quiz@example.com" || series.RRule != "FREQ=WEEKLY;COUNT=4" || !series.Start.Equal(time.Date(2026, 11, 9, 20, 0, 0, 0, time.UTC)) {
+		t.Errorf("series = %+v, want the weekly quiz starting 2026-11-09", series)
+	}
+	want := []time.Time{time.Date(2026, 11, 23, 20, 0, 0, 0, time.UTC), time.Date(2026, 11, 16, 20, 0, 0, 0, time.UTC)}
+	if !reflect.DeepEqual(series.ExDates, want) {
+		t.Errorf("series except dates = %v, want %v", series.ExDates, want)
+	}
+	if len(events[1].ExDates) != 1 {
+		t.Errorf("the parsed events were changed, except dates %v", events[1].ExDates)
+	}
+
+	// the override is an event of its own with a uid that stays the same when it is read again
+	if override.UID != "quiz@example.com-20261116T200000" || override.Summary != "Quiz (moved)" || !override.RecurrenceID.IsZero() || override.RRule != "" {
+		t.Errorf("override = %+v, want its own event with uid quiz@example.com-20261116T200000", override)
+	}
+	// an override without its series in the file is still an event of its own
+	if lone.UID != "fair@example.com-20261201" || !lone.AllDay {
+		t.Errorf("override without a series = %+v, want uid fair@example.com-20261201", lone)
+	}
+	if again := ApplyOverrides(events); !reflect.DeepEqual(again, applied) {
+		t.Errorf("applying the overrides again gave %+v, want %+v", again, applied)
+	}
+}
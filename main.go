@@ -1,25 +1,40 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"os/signal"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 
+	"github.com/ben-23-96/go_events_cli/clash"
+	"github.com/ben-23-96/go_events_cli/config"
 	"github.com/ben-23-96/go_events_cli/database"
 	"github.com/ben-23-96/go_events_cli/eventsearch"
+	"github.com/ben-23-96/go_events_cli/output"
+	"github.com/ben-23-96/go_events_cli/tui"
 )
 
 func main() {
-	// load .env file
+	// load .env file, the api keys can also be set in the environment or the config file
 	err := godotenv.Load(".env")
-
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Printf("Warning: error loading .env file: %s\n", err)
+	}
+	// the config file gives the defaults of the flags, a broken config file is reported and ignored
+	cfg, err := config.Load()
 	if err != nil {
-		fmt.Printf("error loading envirment variables, ticketmaster api keys.")
-		return
+		fmt.Printf("Warning: %s\n", err)
 	}
+	exportAPIKeys(&cfg)
 	// define calendar subcommand
 	calendarCmd := flag.NewFlagSet("calendar", flag.ExitOnError)
 	// calendar subcommand vars
@@ -27,42 +42,62 @@ func main() {
 	var deleteEvent string
 	var displayUpcomingEvents bool
 	// calendar subcommand flags
-	calendarCmd.StringVar(&newEvents, "add-events", "", "Events and the date they are on to be added to calendar, comma seperated list in quotation marks. Example: \"event name, date, event name 2, date 2\"")
+	calendarCmd.StringVar(&newEvents, "add-events", "", "Events and the date they are on to be added to calendar, comma seperated list in quotation marks. Example: \"event name, date, event name 2, date 2\". A date can include a start time or start and end time, eg 2023-11-05 19:30-23:00")
 
 	calendarCmd.StringVar(&deleteEvent, "delete-event", "", "Delete a event from the calendar, provided the name of the event as it is stored. Example: \"event name\"")
 
 	calendarCmd.BoolVar(&displayUpcomingEvents, "upcoming-events", false, "Display the upcoming events in the calendar.")
 
-	// define search subcommand
-	eventSearchCmd := flag.NewFlagSet("search", flag.ExitOnError)
-	// search subcommand vars
-	var cities string
-	var genres string
-	var dateFrom string
-	var dateTo string
-	// Set default values for dateFrom and dateTo
-	defaultDateFrom := time.Now().Format(time.DateOnly)
-	defaultDateTo := time.Now().AddDate(0, 1, 0).Format(time.DateOnly)
-	// search subcommand flags
-	eventSearchCmd.StringVar(&cities, "cities", "", "Indivual city or comma seperated list of cities. Example: \"Manchester,Brisol\"")
-	eventSearchCmd.StringVar(&genres, "genres", "", "Indivual genre or subgenre comma seperated list. Example: \"Music,Sport\" Example2: \"Techno,Football\"")
-	eventSearchCmd.StringVar(&dateFrom, "date-from", defaultDateFrom, "Date to start searching from in format YYYY-MM-DD. Default current date.")
-	eventSearchCmd.StringVar(&dateTo, "date-to", defaultDateTo, "Date to start searching to in format YYYY-MM-DD. Default 1 month from current date.")
+	// define search subcommand, search save and search run take the same flags
+	eventSearchCmd, searchOpts := newSearchFlags("search", &cfg)
+
+	// options given before the subcommand choose the database used by every subcommand
+	globalCmd := flag.NewFlagSet("go_events_cli", flag.ExitOnError)
+	globalCmd.Usage = func() {
+		fmt.Fprintln(globalCmd.Output(), "Usage: go_events_cli [-db-dir dir] [-calendar name] calendar|search|config ...")
+		globalCmd.PrintDefaults()
+	}
+	dbDir := globalCmd.String("db-dir", configValue(&cfg, "calendar.db-dir"), "Directory of the calendar databases. Default $"+database.DirEnv+", else calendar.db-dir from the config, else go_events_cli in $XDG_DATA_HOME or ~/.local/share.")
+	calendarName := globalCmd.String("calendar", configValue(&cfg, "calendar.name"), "Name of the calendar to use, each calendar is a seperate database. Default $"+database.CalendarEnv+", else calendar.name from the config, else "+database.DefaultCalendar+".")
+	globalCmd.Parse(os.Args[1:])
+	if err := database.SetLocation(*dbDir, *calendarName); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	args := globalCmd.Args()
+
 	// exit if neither subcommand provided
-	if len(os.Args) < 2 {
-		fmt.Println("expected 'calendar' or 'search' subcommands")
+	if len(args) < 1 {
+		fmt.Println("expected 'calendar', 'search' or 'config' subcommands")
 		os.Exit(1)
 	}
 	// call relevant function to handle the arguments of relevant subcommands
-	switch os.Args[1] {
+	switch args[0] {
 	case "calendar":
-		calendarCmd.Parse(os.Args[2:])
+		// calendar subcommands such as update take their own arguments, anything else is parsed as calendar flags
+		if len(args) > 1 {
+			if handler, ok := calendarSubcommands[args[1]]; ok {
+				handler(args[2:])
+				return
+			}
+		}
+		calendarCmd.Parse(args[1:])
 		handleCalendarCmd(newEvents, deleteEvent, displayUpcomingEvents)
+	case "config":
+		handleConfigCmd(args[1:])
 	case "search":
-		eventSearchCmd.Parse(os.Args[2:])
-		handleSearchCmd(cities, genres, dateFrom, dateTo)
+		// saved search subcommands take their own arguments, anything else is parsed as search flags
+		if len(args) > 1 {
+			if handler, ok := searchSubcommands[args[1]]; ok {
+				handler(args[2:], &cfg)
+				return
+			}
+		}
+		eventSearchCmd.Parse(args[1:])
+		searchOpts.search.Providers = eventsearch.SplitList(searchOpts.providers)
+		handleSearchCmd(searchOpts.search, searchOpts.outputFormat, searchOpts.verbose, searchOpts.interactive, searchOpts.calendars, nil)
 	default:
-		fmt.Println("expected 'calendar' or 'search' subcommands")
+		fmt.Println("expected 'calendar', 'search' or 'config' subcommands")
 		os.Exit(1)
 	}
 }
@@ -91,66 +126,191 @@ func handleCalendarCmd(newEvents string, deleteEvent string, displayUpcomingEven
 
 	// Check if the flag to display upcoming events is set then display the events
 	if displayUpcomingEvents {
-		events, err := database.GetEvents(db)
+		events, err := database.UpcomingEvents(db, time.Now())
 		if err != nil {
 			fmt.Printf("Error retrieving events from database. Err: %s\n", err)
 		}
 		fmt.Print("Upcoming Events:\n\n")
 		for _, event := range events {
-			fmt.Printf("%s    %s\n", event.EventName, event.Date)
+			fmt.Printf("%-4d %s    %s    %s\n", event.ID, event.EventName, event.When(), event.Venue)
 		}
 	}
 }
 
 /*
-Handles the search subcommand. Makes requests to the ticketmaster and skiddle API's searching for events using the paramters provided by the user in the CLI flags. Prints the found events in terminal checking if they do not clash with events in the calendar.
+Handles the search subcommand. Makes requests to the API's of the selected providers searching for events using the paramters provided by the user in the CLI flags. Writes the found events in the output format checking if they do not clash with events in the calendar. Warnings and the provider report are written to stderr for every format except table so the output can be piped into other tools.
+A run of a saved search is compared with the previous run, run is nil for a search that is not saved.
 */
-func handleSearchCmd(cities string, genres string, dateFromString string, dateToString string) {
-	db, err := database.InitDB()
+func handleSearchCmd(eventSearch eventsearch.ApiSearch, outputFormat string, verbose bool, interactive bool, calendars string, run *savedRun) {
+	if err := output.ValidateFormat(outputFormat); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	// diagnostics go to stdout with the table but must not corrupt machine readable formats
+	var diagnostics io.Writer = os.Stdout
+	if outputFormat != "table" {
+		diagnostics = os.Stderr
+	}
+
+	// cancel in-flight requests on Ctrl-C, the events already received are still printed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
+	// the chosen calendar keeps the search results and is where interactive adds go
+	db, err := database.InitDB()
 	if err != nil {
-		fmt.Printf("error initializing database: %s", err)
+		fmt.Fprintf(diagnostics, "error initializing database: %s\n", err)
+	} else {
+		defer db.Close()
 	}
-	// get calendarEvents from calendar
-	var calendarEvents []database.CalendarEvent
-	calendarEvents, err = database.GetEvents(db)
+	// get the busy periods of the calendars and the calendars they subscribe to, the search still runs without clash checking if a calendar cannot be read
+	calendarNames, err := searchCalendarNames(calendars)
 	if err != nil {
-		fmt.Printf("Error retrieving events from database. Err: %s\n", err)
+		fmt.Fprintf(diagnostics, "Search not made, %s\n", err)
+		os.Exit(1)
 	}
-
-	// create new instance of api search struct with arguments
-	eventSearch := eventsearch.ApiSearch{
-		Cities:       cities,
-		Genres:       genres,
-		DateFrom:     dateFromString,
-		DateTo:       dateToString,
-		Ticketmaster: true,
-		Skiddle:      true,
+	// past events cannot clash with the search results, repeating events are expanded to the end of the search
+	now := time.Now()
+	filter := database.ListFilter{From: now}
+	if dateTo, err := eventsearch.ResolveDate(eventSearch.DateTo, now, true); err == nil {
+		filter.To = dateTo.AddDate(0, 0, 1)
+	}
+	busy, busyWarnings := calendarBusyPeriods(ctx, calendarNames, filter)
+	for _, warning := range busyWarnings {
+		fmt.Fprintf(diagnostics, "Warning: %s\n", warning)
 	}
 	// search for events
-	foundEvents := eventSearch.Search()
-	// Create a map for calendar events
-	calendarMap := make(map[time.Time]string)
-
-	// Iterate through calendar events and populate the map
-	for _, calendarEvent := range calendarEvents {
-		date, _ := time.Parse(time.DateOnly, calendarEvent.Date)
-		calendarMap[date] = calendarEvent.EventName
-	}
-	// Iterate through found events and check if they clash with a calendar event date with a map lookup
-	for _, foundEvent := range foundEvents {
-		// format date to string for print
-		foundEventDate := foundEvent.Date.Format(time.DateOnly)
-		if eventName, ok := calendarMap[foundEvent.Date]; !ok {
-			// The date doesn't clash with a date in the calendar, print the event details
-			fmt.Println("Event: ", foundEvent.Name)
-			fmt.Println("city", foundEvent.City)
-			fmt.Println("date", foundEventDate)
-			fmt.Println("tickets", foundEvent.Tickets)
-			fmt.Printf("genre: %s, subgenre: %s\n\n", foundEvent.Genre, foundEvent.Subgenre)
+	result, err := eventSearch.Search(ctx)
+	if err != nil {
+		fmt.Fprintf(diagnostics, "Search not made, invalid search:\n%s\n", err)
+		os.Exit(1)
+	}
+	if ctx.Err() != nil {
+		fmt.Fprint(diagnostics, "Search cancelled, showing the events received so far.\n\n")
+	}
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(diagnostics, "Warning: %s\n", warning)
+	}
+	if verbose {
+		printSearchDetails(diagnostics, result)
+	}
+	// check every found event against the calendar, events without a start time are treated as all day
+	var rows []output.Row
+	for _, foundEvent := range result.Events {
+		var clashResult clash.Result
+		if foundEvent.Start.IsZero() {
+			clashResult = clash.Check(foundEvent.Date, time.Time{}, true, busy)
+		} else {
+			clashResult = clash.Check(foundEvent.Start, foundEvent.End, false, busy)
+		}
+		rows = append(rows, output.Row{Event: foundEvent, Clash: clashResult})
+	}
+	// cache the results so they can be added to the calendar by ref
+	if db != nil {
+		if err := cacheSearchResults(db, result.Events); err != nil {
+			fmt.Fprintf(diagnostics, "Warning: failed to save search results, calendar add-from-search will use the previous search: %s\n", err)
+		}
+	}
+	if run != nil && db != nil {
+		rows = run.compare(diagnostics, db, rows, searchComplete(ctx, result))
+	}
+	// the interactive list falls back to the output format when not running in a terminal
+	shown := false
+	if interactive {
+		err := tui.Run(rows, func(event eventsearch.FoundEvent) (string, error) {
+			if db == nil {
+				return "", errors.New("the calendar could not be opened")
+			}
+			calendarEvent, err := database.AddSearchResult(db, event.Ref())
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("added %s to the calendar as event %d", calendarEvent.EventName, calendarEvent.ID), nil
+		})
+		if err != nil {
+			fmt.Fprintf(diagnostics, "Warning: %s, showing the results as %s\n", err, outputFormat)
 		} else {
-			// The event date clashes with event in the calendar
-			fmt.Printf("CALENDAR CLASH: %s (Event: %s)\n\n", foundEventDate, eventName)
+			shown = true
+		}
+	}
+	if !shown {
+		if err := output.Write(os.Stdout, outputFormat, rows); err != nil {
+			fmt.Fprintf(diagnostics, "error writing output: %s\n", err)
+		}
+	}
+
+	printProviderReports(diagnostics, result.Providers, verbose)
+	// a cancelled search has printed what it received, it exits as interrupted rather than as a search where every provider failed
+	if ctx.Err() != nil {
+		os.Exit(130)
+	}
+	// only fail the command if no provider returned anything
+	if eventsearch.AllProvidersFailed(result.Providers) {
+		os.Exit(1)
+	}
+}
+
+/*
+Saves the found events of a search as the last search results, replacing the results of the previous search.
+*/
+func cacheSearchResults(db *sql.DB, events []eventsearch.FoundEvent) error {
+	var results []database.SearchResult
+	for _, event := range events {
+		result := database.SearchResult{
+			Ref:       event.Ref(),
+			EventName: event.Name,
+			Start:     event.Date,
+			AllDay:    true,
+			City:      event.City,
+			Genre:     output.GenreText(event),
+			Provider:  event.Provider,
+			Tickets:   event.Tickets,
+		}
+		if !event.Start.IsZero() {
+			result.Start, result.End, result.AllDay = event.Start, event.End, false
 		}
+		results = append(results, result)
+	}
+	return database.SaveSearchResults(db, results)
+}
+
+/*
+Prints the number of requests, failures and events of each provider in the search, followed by the errors of any failed requests. Requests stopped by Ctrl-C are counted as cancelled rather than printed as errors. When verbose the time taken by each request is included.
+*/
+func printProviderReports(w io.Writer, reports []eventsearch.ProviderReport, verbose bool) {
+	fmt.Fprint(w, "\nProviders:\n\n")
+	for _, report := range reports {
+		fmt.Fprintf(w, "%s: %d requests, %d failed, %d events, took %s\n", report.Provider, report.Requests, report.Failed, report.Events, report.Duration.Round(time.Millisecond))
+		if verbose {
+			for i, duration := range report.RequestDurations {
+				fmt.Fprintf(w, "    request %d: %s\n", i+1, duration.Round(time.Millisecond))
+			}
+		}
+		cancelled := 0
+		for _, err := range report.Errors {
+			if errors.Is(err, context.Canceled) {
+				cancelled++
+				continue
+			}
+			fmt.Fprintf(w, "    error: %s\n", err)
+		}
+		if cancelled > 0 {
+			fmt.Fprintf(w, "    requests cancelled: %d\n", cancelled)
+		}
+	}
+}
+
+/*
+Prints the genres each provider matched the user genres to and the locations the cities were geocoded to.
+*/
+func printSearchDetails(w io.Writer, result eventsearch.SearchResult) {
+	for _, report := range result.Providers {
+		if genres, ok := result.Genres[report.Provider]; ok {
+			fmt.Fprintf(w, "%s genres: %s\n", report.Provider, strings.Join(genres, ", "))
+		}
+	}
+	for _, cityLocation := range result.Locations {
+		fmt.Fprintf(w, "%s: %f, %f\n", cityLocation.City, cityLocation.Location.Lat, cityLocation.Location.Lng)
 	}
+	fmt.Fprintf(w, "search took %s\n\n", result.Duration.Round(time.Millisecond))
 }
@@ -0,0 +1,386 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ben-23-96/go_events_cli/changes"
+	"github.com/ben-23-96/go_events_cli/clash"
+	"github.com/ben-23-96/go_events_cli/eventsearch"
+	"github.com/ben-23-96/go_events_cli/ics"
+)
+
+// format of the start and end times of found events in output, times are local to the event
+const dateTimeFormat = "2006-01-02T15:04"
+
+// Formats supported by Write, table is the default human readable format.
+var Formats = []string{"table", "json", "ndjson", "csv", "markdown", "ics"}
+
+// Row is a found event with the result of checking it against the calendar, one row of search output.
+type Row struct {
+	Event eventsearch.FoundEvent
+	Clash clash.Result
+	// how the event differs from the previous run of a saved search, the Status is "" when the search was not compared
+	Change changes.Change
+}
+
+// JSONEvent is the stable JSON schema of a row used by the json and ndjson formats, fields are only ever added to it.
+type JSONEvent struct {
+	Ref      string       `json:"ref"`
+	Name     string       `json:"name"`
+	Date     string       `json:"date"`
+	Start    string       `json:"start,omitempty"`
+	End      string       `json:"end,omitempty"`
+	City     string       `json:"city"`
+	Genre    string       `json:"genre"`
+	Subgenre string       `json:"subgenre"`
+	Provider string       `json:"provider"`
+	Tickets  string       `json:"tickets"`
+	Sources  []JSONSource `json:"sources"`
+	Clash    JSONClash    `json:"clash"`
+	// only set when the search was compared with its previous run
+	Change *JSONChange `json:"change,omitempty"`
+}
+
+// JSONSource is the listing of an event on one provider.
+type JSONSource struct {
+	Provider string `json:"provider"`
+	Tickets  string `json:"tickets"`
+}
+
+// JSONClash is the clash status of an event, status is "none", "same-day" or "clash".
+type JSONClash struct {
+	Status string `json:"status"`
+	// calendar events the event overlaps
+	CalendarEvents []string `json:"calendarEvents"`
+	// calendar events on the same day that the event does not overlap
+	SameDayEvents []string `json:"sameDayEvents"`
+}
+
+// JSONChange is how an event differs from the previous run of a saved search, status is "new", "changed" or "removed".
+type JSONChange struct {
+	Status string `json:"status"`
+	// start of a changed event before it moved
+	PreviousStart string `json:"previousStart,omitempty"`
+	// ticket links of a changed event before they changed
+	PreviousTickets []string `json:"previousTickets,omitempty"`
+}
+
+/*
+Checks the format is one of Formats.
+*/
+func ValidateFormat(format string) error {
+	for _, supported := range Formats {
+		if format == supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format %s, supported formats are %s", format, strings.Join(Formats, ", "))
+}
+
+/*
+Write writes the rows of a search to w in the format.
+Parameters:
+- w: io.Writer: where to write the output.
+- format: string: one of Formats.
+- rows: []Row: the found events and their clash status.
+Returns:
+- error: if the format is unknown or writing fails.
+*/
+func Write(w io.Writer, format string, rows []Row) error {
+	switch format {
+	case "table":
+		return writeTable(w, rows)
+	case "json":
+		return writeJSON(w, rows)
+	case "ndjson":
+		return writeNDJSON(w, rows)
+	case "csv":
+		return writeCSV(w, rows)
+	case "markdown":
+		return writeMarkdown(w, rows)
+	case "ics":
+		return writeICS(w, rows)
+	}
+	return ValidateFormat(format)
+}
+
+/*
+Converts a row to the stable JSON schema.
+*/
+func ToJSONEvent(row Row) JSONEvent {
+	event := row.Event
+	jsonEvent := JSONEvent{
+		Ref:      event.Ref(),
+		Name:     event.Name,
+		Date:     event.Date.Format(time.DateOnly),
+		City:     event.City,
+		Genre:    event.Genre,
+		Subgenre: event.Subgenre,
+		Provider: event.Provider,
+		Tickets:  event.Tickets,
+		Sources:  []JSONSource{},
+		Clash: JSONClash{
+			Status:         string(clashStatus(row.Clash)),
+			CalendarEvents: append([]string{}, periodNames(row.Clash.Clashes)...),
+			SameDayEvents:  append([]string{}, periodNames(row.Clash.SameDay)...),
+		},
+	}
+	if !event.Start.IsZero() {
+		jsonEvent.Start = event.Start.Format(dateTimeFormat)
+	}
+	if !event.End.IsZero() {
+		jsonEvent.End = event.End.Format(dateTimeFormat)
+	}
+	for _, source := range event.Sources {
+		jsonEvent.Sources = append(jsonEvent.Sources, JSONSource{Provider: source.Provider, Tickets: source.Tickets})
+	}
+	if row.Change.Status != "" {
+		jsonEvent.Change = &JSONChange{Status: string(row.Change.Status), PreviousTickets: row.Change.PreviousTickets}
+		if !row.Change.PreviousStart.IsZero() {
+			jsonEvent.Change.PreviousStart = row.Change.PreviousStart.Format(dateTimeFormat)
+		}
+	}
+	return jsonEvent
+}
+
+// writes the rows as an indented json array
+func writeJSON(w io.Writer, rows []Row) error {
+	jsonEvents := []JSONEvent{}
+	for _, row := range rows {
+		jsonEvents = append(jsonEvents, ToJSONEvent(row))
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(jsonEvents)
+}
+
+// writes one json object per line
+func writeNDJSON(w io.Writer, rows []Row) error {
+	encoder := json.NewEncoder(w)
+	for _, row := range rows {
+		if err := encoder.Encode(ToJSONEvent(row)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writes the rows as csv with a header row, the ticket links of merged events are seperated by spaces. A change column is added when the rows were compared with a previous run.
+func writeCSV(w io.Writer, rows []Row) error {
+	writer := csv.NewWriter(w)
+	compared := hasChanges(rows)
+	header := []string{"date", "name", "city", "genre", "subgenre", "providers", "tickets", "clash", "clash_events", "start", "end", "same_day_events", "ref"}
+	if compared {
+		header = append(header, "change")
+	}
+	writer.Write(header)
+	for _, row := range rows {
+		providers, tickets := sourceLists(row.Event)
+		record := []string{
+			row.Event.Date.Format(time.DateOnly),
+			row.Event.Name,
+			row.Event.City,
+			row.Event.Genre,
+			row.Event.Subgenre,
+			strings.Join(providers, " "),
+			strings.Join(tickets, " "),
+			string(clashStatus(row.Clash)),
+			strings.Join(periodNames(row.Clash.Clashes), "; "),
+			formatTime(row.Event.Start),
+			formatTime(row.Event.End),
+			strings.Join(periodNames(row.Clash.SameDay), "; "),
+			row.Event.Ref(),
+		}
+		if compared {
+			record = append(record, row.Change.Text())
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writes the rows as aligned columns, clashing events are marked with the calendar events they clash with. Rows compared with a previous run start with the change.
+func writeTable(w io.Writer, rows []Row) error {
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	compared := hasChanges(rows)
+	if compared {
+		fmt.Fprint(writer, "CHANGE\t")
+	}
+	fmt.Fprintln(writer, "REF\tDATE\tEVENT\tCITY\tGENRE\tPROVIDERS\tCLASH\tTICKETS")
+	for _, row := range rows {
+		providers, tickets := sourceLists(row.Event)
+		if compared {
+			fmt.Fprintf(writer, "%s\t", row.Change.Text())
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			row.Event.Ref(),
+			dateText(row.Event),
+			row.Event.Name,
+			row.Event.City,
+			GenreText(row.Event),
+			strings.Join(providers, ","),
+			clashText(row.Clash),
+			strings.Join(tickets, " "),
+		)
+	}
+	return writer.Flush()
+}
+
+// writes the rows as a github flavoured markdown table, rows compared with a previous run start with the change
+func writeMarkdown(w io.Writer, rows []Row) error {
+	compared := hasChanges(rows)
+	if compared {
+		fmt.Fprint(w, "| Change ")
+	}
+	fmt.Fprintln(w, "| Ref | Date | Event | City | Genre | Providers | Clash | Tickets |")
+	if compared {
+		fmt.Fprint(w, "| --- ")
+	}
+	fmt.Fprintln(w, "| --- | --- | --- | --- | --- | --- | --- | --- |")
+	for _, row := range rows {
+		if compared {
+			fmt.Fprintf(w, "| %s ", markdownEscape(row.Change.Text()))
+		}
+		var links []string
+		for _, source := range row.Event.Sources {
+			links = append(links, fmt.Sprintf("[%s](%s)", markdownEscape(source.Provider), source.Tickets))
+		}
+		providers, _ := sourceLists(row.Event)
+		_, err := fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s | %s | %s |\n",
+			row.Event.Ref(),
+			dateText(row.Event),
+			markdownEscape(row.Event.Name),
+			markdownEscape(row.Event.City),
+			markdownEscape(GenreText(row.Event)),
+			markdownEscape(strings.Join(providers, ", ")),
+			markdownEscape(clashText(row.Clash)),
+			strings.Join(links, " "),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writes the rows as VEVENTs so they can be imported into a calendar, events without a start time are all day. Removed events are left out as they are no longer listed.
+func writeICS(w io.Writer, rows []Row) error {
+	var events []ics.Event
+	for _, row := range rows {
+		if row.Change.Status == changes.Removed {
+			continue
+		}
+		_, tickets := sourceLists(row.Event)
+		description := GenreText(row.Event)
+		if len(tickets) > 0 {
+			description += "\nTickets: " + strings.Join(tickets, " ")
+		}
+		event := ics.Event{
+			UID:         foundEventUID(row.Event),
+			Summary:     row.Event.Name,
+			Start:       row.Event.Date,
+			AllDay:      true,
+			Location:    row.Event.City,
+			URL:         row.Event.Tickets,
+			Description: description,
+		}
+		if !row.Event.Start.IsZero() {
+			event.Start, event.End, event.AllDay = row.Event.Start, row.Event.End, false
+		}
+		events = append(events, event)
+	}
+	return ics.Write(w, events)
+}
+
+// returns the providers and ticket links of every listing of an event
+func sourceLists(event eventsearch.FoundEvent) ([]string, []string) {
+	var providers, tickets []string
+	for _, source := range event.Sources {
+		providers = append(providers, source.Provider)
+		tickets = append(tickets, source.Tickets)
+	}
+	if len(event.Sources) == 0 {
+		providers = append(providers, event.Provider)
+		tickets = append(tickets, event.Tickets)
+	}
+	return providers, tickets
+}
+
+// GenreText returns the genre and subgenre of an event seperated by a slash.
+func GenreText(event eventsearch.FoundEvent) string {
+	if event.Subgenre == "" || event.Subgenre == event.Genre {
+		return event.Genre
+	}
+	return event.Genre + "/" + event.Subgenre
+}
+
+// the date of an event followed by its start time when the time is known
+func dateText(event eventsearch.FoundEvent) string {
+	if event.Start.IsZero() {
+		return event.Date.Format(time.DateOnly)
+	}
+	return event.Start.Format("2006-01-02 15:04")
+}
+
+// a time in dateTimeFormat, "" if the time is not known
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(dateTimeFormat)
+}
+
+// the status of a result, results that were never checked have no clash
+func clashStatus(result clash.Result) clash.Status {
+	if result.Status == "" {
+		return clash.None
+	}
+	return result.Status
+}
+
+// the names of busy periods in the order they were found
+func periodNames(periods []clash.BusyPeriod) []string {
+	var names []string
+	for _, period := range periods {
+		names = append(names, period.Name)
+	}
+	return names
+}
+
+// the calendar events a found event clashes with or shares a day with, or "-" if there are none
+func clashText(result clash.Result) string {
+	switch result.Status {
+	case clash.Overlap:
+		return "CLASH: " + strings.Join(periodNames(result.Clashes), ", ")
+	case clash.SameDay:
+		return "same day: " + strings.Join(periodNames(result.SameDay), ", ")
+	}
+	return "-"
+}
+
+// reports whether any row was compared with a previous run
+func hasChanges(rows []Row) bool {
+	for _, row := range rows {
+		if row.Change.Status != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// escapes pipes so text does not break a markdown table
+func markdownEscape(text string) string {
+	return strings.ReplaceAll(text, "|", `\|`)
+}
+
+// a uid for a found event made from its ref, it stays the same across searches so re-importing results does not duplicate them
+func foundEventUID(event eventsearch.FoundEvent) string {
+	return event.Ref() + "@go_events_cli"
+}
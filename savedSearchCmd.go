@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ben-23-96/go_events_cli/config"
+	"github.com/ben-23-96/go_events_cli/database"
+	"github.com/ben-23-96/go_events_cli/eventsearch"
+)
+
+// subcommands of the search command for saved searches, other arguments to search are search flags
+var searchSubcommands = map[string]func(args []string, cfg *config.Config){
+	"save":         handleSearchSaveCmd,
+	"run":          handleSearchRunCmd,
+	"list-saved":   handleSearchListSavedCmd,
+	"delete-saved": handleSearchDeleteSavedCmd,
+}
+
+/*
+Handles the search save subcommand. Validates the search flags and saves them under the name, dates are saved as given so relative dates are resolved each time the search is run.
+Parameters:
+- args: the arguments after save, the name followed by the search flags.
+- cfg: *config.Config: the config giving the defaults of the flags.
+*/
+func handleSearchSaveCmd(args []string, cfg *config.Config) {
+	saveCmd, opts := newSearchFlags("search save", cfg)
+	saveCmd.Usage = func() {
+		fmt.Fprintln(saveCmd.Output(), "Usage: search save <name> [search flags]")
+		saveCmd.PrintDefaults()
+	}
+	name, err := parseNameArg(saveCmd, args)
+	if err != nil {
+		fmt.Println(err)
+		saveCmd.Usage()
+		os.Exit(2)
+	}
+	opts.search.Providers = eventsearch.SplitList(opts.providers)
+	if err := opts.search.Validate(); err != nil {
+		fmt.Printf("Search not saved, invalid search:\n%s\n", err)
+		os.Exit(1)
+	}
+
+	db, err := database.InitDB()
+	if err != nil {
+		fmt.Printf("error initializing database: %s\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	search := database.SavedSearch{
+		Name:      name,
+		Cities:    opts.search.Cities,
+		Genres:    opts.search.Genres,
+		DateFrom:  opts.search.DateFrom,
+		DateTo:    opts.search.DateTo,
+		Providers: opts.search.Providers,
+	}
+	replaced, err := database.SaveSearch(db, search)
+	if err != nil {
+		fmt.Printf("Search not saved: %s\n", err)
+		os.Exit(1)
+	}
+	if replaced {
+		fmt.Printf("Saved search %s updated\n", name)
+	} else {
+		fmt.Printf("Saved search %s, run it with: search run %s\n", name, name)
+	}
+	// a fixed date is not moved on when the search is run again
+	for _, date := range []string{search.DateFrom, search.DateTo} {
+		if _, err := time.Parse(time.DateOnly, date); err == nil {
+			fmt.Printf("Note: %s is a fixed date, use a relative date such as today or +30d for a window that moves with each run\n", date)
+		}
+	}
+}
+
+/*
+Handles the search run subcommand. Runs the saved search with the name, resolving its relative dates against today. Search flags given after the name override the saved parameters for this run only. With -new-only only the events that are new, changed or removed since the previous run are shown.
+Parameters:
+- args: the arguments after run, the name followed by any search flags.
+- cfg: *config.Config: the config giving the defaults of the flags.
+*/
+func handleSearchRunCmd(args []string, cfg *config.Config) {
+	runCmd, opts := newSearchFlags("search run", cfg)
+	runCmd.Usage = func() {
+		fmt.Fprintln(runCmd.Output(), "Usage: search run <name> [-new-only] [search flags]")
+		runCmd.PrintDefaults()
+	}
+	newOnly := runCmd.Bool("new-only", false, "Only show the events that are new, have a new date or ticket link, or are no longer listed since the previous run of the saved search.")
+	name, err := parseNameArg(runCmd, args)
+	if err != nil {
+		fmt.Println(err)
+		runCmd.Usage()
+		os.Exit(2)
+	}
+
+	db, err := database.InitDB()
+	if err != nil {
+		fmt.Printf("error initializing database: %s\n", err)
+		os.Exit(1)
+	}
+	saved, err := database.GetSavedSearch(db, name)
+	if err != nil {
+		db.Close()
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// the saved parameters are used unless the flag was given
+	given := make(map[string]bool)
+	runCmd.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	if !given["cities"] {
+		opts.search.Cities = saved.Cities
+	}
+	if !given["genres"] {
+		opts.search.Genres = saved.Genres
+	}
+	if !given["date-from"] {
+		opts.search.DateFrom = saved.DateFrom
+	}
+	if !given["date-to"] {
+		opts.search.DateTo = saved.DateTo
+	}
+	opts.search.Providers = saved.Providers
+	if given["providers"] {
+		opts.search.Providers = eventsearch.SplitList(opts.providers)
+	}
+	run := &savedRun{
+		search:     saved,
+		newOnly:    *newOnly,
+		overridden: given["cities"] || given["genres"] || given["date-from"] || given["date-to"] || given["providers"],
+	}
+
+	if err := database.SetSavedSearchRun(db, saved.ID); err != nil {
+		fmt.Printf("Warning: failed to record the run of %s: %s\n", saved.Name, err)
+	}
+	db.Close()
+	handleSearchCmd(opts.search, opts.outputFormat, opts.verbose, opts.interactive, opts.calendars, run)
+}
+
+/*
+Handles the search list-saved subcommand, listing each saved search with its parameters and when it was last run.
+*/
+func handleSearchListSavedCmd(args []string, cfg *config.Config) {
+	db, err := database.InitDB()
+	if err != nil {
+		fmt.Printf("error initializing database: %s\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	searches, err := database.GetSavedSearches(db)
+	if err != nil {
+		fmt.Printf("Error retrieving saved searches from database. Err: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Print("Saved searches:\n\n")
+	for _, search := range searches {
+		lastRun := "never run"
+		if !search.LastRunAt.IsZero() {
+			lastRun = "last run " + search.LastRunAt.Local().Format("2006-01-02 15:04")
+		}
+		genres := search.Genres
+		if genres == "" {
+			genres = "all genres"
+		}
+		fmt.Printf("%s    %s    %s    %s to %s    %s    %s\n", search.Name, search.Cities, genres, search.DateFrom, search.DateTo, strings.Join(search.Providers, ","), lastRun)
+	}
+}
+
+/*
+Handles the search delete-saved subcommand, deleting the saved searches with the names.
+Parameters:
+- args: the arguments after delete-saved, the names of the saved searches.
+*/
+func handleSearchDeleteSavedCmd(args []string, cfg *config.Config) {
+	if len(args) == 0 {
+		fmt.Println("Usage: search delete-saved <name> [name...]")
+		os.Exit(2)
+	}
+	db, err := database.InitDB()
+	if err != nil {
+		fmt.Printf("error initializing database: %s\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	failed := false
+	for _, name := range args {
+		if err := database.DeleteSavedSearch(db, name); err != nil {
+			fmt.Printf("Saved search not deleted: %s\n", err)
+			failed = true
+			continue
+		}
+		fmt.Printf("Deleted saved search %s\n", name)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+/*
+Parses the flags of a subcommand that takes a name, the name can come before or after the flags.
+Returns:
+- string: the name.
+- error: if the name is missing.
+*/
+func parseNameArg(flagSet *flag.FlagSet, args []string) (string, error) {
+	var name string
+	// name before the flags, the flag package stops parsing at the first non flag argument
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name = args[0]
+		args = args[1:]
+	}
+	flagSet.Parse(args)
+	if name == "" {
+		name = flagSet.Arg(0)
+	}
+	if strings.TrimSpace(name) == "" {
+		return "", errors.New("missing saved search name")
+	}
+	return name, nil
+}
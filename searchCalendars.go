@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ben-23-96/go_events_cli/clash"
+	"github.com/ben-23-96/go_events_cli/database"
+)
+
+/*
+Resolves the -calendars flag of search to the names of the calendars to check for clashes.
+Parameters:
+- calendars: string: comma seperated calendar names, all for every calendar, "" for the calendar chosen with -calendar.
+Returns:
+- []string: the calendar names.
+- error: if a name is not a valid calendar name or there is no calendar with the name.
+*/
+func searchCalendarNames(calendars string) ([]string, error) {
+	switch strings.TrimSpace(calendars) {
+	case "":
+		return []string{database.CurrentCalendar()}, nil
+	case "all":
+		return database.Calendars()
+	}
+	var names []string
+	for _, name := range strings.Split(calendars, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		path, err := database.CalendarPath(name)
+		if err != nil {
+			return nil, err
+		}
+		// opening a calendar creates it, so a mistyped name would silently check nothing
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) && name != database.CurrentCalendar() {
+			return nil, fmt.Errorf("no calendar named %s, list the calendars with: calendar calendars", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+/*
+Reads the busy periods found events are checked against: the events of each calendar in the filter's range and the events of the remote calendars each one subscribes to. A calendar that cannot be read is reported as a warning and skipped.
+Parameters:
+- names: []string: the calendars to read.
+- filter: database.ListFilter: the range of the search.
+Returns:
+- []clash.BusyPeriod: the busy periods, named after their calendar when more than one calendar is checked and after their subscription for subscription events.
+- []error: warnings about calendars and subscriptions that could not be read.
+*/
+func calendarBusyPeriods(ctx context.Context, names []string, filter database.ListFilter) ([]clash.BusyPeriod, []error) {
+	var busy []clash.BusyPeriod
+	var warnings []error
+	for _, name := range names {
+		db, err := database.OpenCalendar(name)
+		if err != nil {
+			warnings = append(warnings, fmt.Errorf("calendar %s not checked for clashes: %v", name, err))
+			continue
+		}
+		calendarEvents, err := database.ListEvents(db, filter)
+		if err != nil {
+			warnings = append(warnings, fmt.Errorf("calendar %s not checked for clashes: %v", name, err))
+		}
+		for _, calendarEvent := range calendarEvents {
+			eventName := calendarEvent.EventName
+			if len(names) > 1 {
+				eventName = fmt.Sprintf("%s (%s)", eventName, name)
+			}
+			busy = append(busy, clash.BusyPeriod{
+				Name:   eventName,
+				Start:  calendarEvent.Start,
+				End:    calendarEvent.End,
+				AllDay: calendarEvent.AllDay,
+			})
+		}
+		// subscription events are named after their subscription so it is clear where the clash comes from
+		subscriptionEvents, subscriptionWarnings := subscriptionBusyEvents(ctx, db, filter)
+		warnings = append(warnings, subscriptionWarnings...)
+		for _, subscriptionEvent := range subscriptionEvents {
+			busy = append(busy, clash.BusyPeriod{
+				Name:   fmt.Sprintf("%s (%s)", subscriptionEvent.EventName, subscriptionEvent.Subscription),
+				Start:  subscriptionEvent.Start,
+				End:    subscriptionEvent.End,
+				AllDay: subscriptionEvent.AllDay,
+			})
+		}
+		db.Close()
+	}
+	return busy, warnings
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ben-23-96/go_events_cli/database"
+)
+
+func TestCalendarBusyPeriodsIncludesSubscriptions(t *testing.T) {
+	if err := database.SetLocation(t.TempDir(), "work"); err != nil {
+		t.Fatal(err)
+	}
+	db, err := database.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	subscription, err := database.AddSubscription(db, "team", "/unused.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a weekly meeting with the second week skipped, refreshed now so it is not fetched again
+	standup := database.SubscriptionEvent{
+		UID:       "standup@example.com",
+		EventName: "Standup",
+		Start:     time.Date(2026, 10, 20, 10, 0, 0, 0, time.UTC),
+		End:       time.Date(2026, 10, 20, 11, 0, 0, 0, time.UTC),
+		RRule:     "FREQ=WEEKLY;COUNT=4",
+		ExDates:   []time.Time{time.Date(2026, 10, 27, 0, 0, 0, 0, time.UTC)},
+	}
+	if err := database.ReplaceSubscriptionEvents(db, subscription.ID, []database.SubscriptionEvent{standup}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	filter := database.ListFilter{From: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)}
+	busy, warnings := calendarBusyPeriods(context.Background(), []string{"work"}, filter)
+	if len(warnings) > 0 {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+	var starts []string
+	for _, period := range busy {
+		if period.Name != "Standup (team)" {
+			t.Errorf("busy period named %q, want Standup (team)", period.Name)
+		}
+		if period.End.Sub(period.Start) != time.Hour {
+			t.Errorf("busy period %s lasts %s, want 1h", period.Start, period.End.Sub(period.Start))
+		}
+		starts = append(starts, period.Start.Format("2006-01-02 15:04"))
+	}
+	want := []string{"2026-10-20 10:00", "2026-11-03 10:00", "2026-11-10 10:00"}
+	if len(starts) != len(want) {
+		t.Fatalf("busy periods start %v, want %v", starts, want)
+	}
+	for i := range want {
+		if starts[i] != want[i] {
+			t.Errorf("busy period %d starts %s, want %s", i, starts[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ben-23-96/go_events_cli/changes"
+	"github.com/ben-23-96/go_events_cli/database"
+	"github.com/ben-23-96/go_events_cli/eventsearch"
+	"github.com/ben-23-96/go_events_cli/output"
+)
+
+// a run of a saved search, its results are compared with the previous run and kept for the next one
+type savedRun struct {
+	search database.SavedSearch
+	// only write the events that are new, changed or removed since the previous run
+	newOnly bool
+	// search flags replaced saved parameters, the results are not kept as they are not the results of the saved search
+	overridden bool
+}
+
+/*
+Compares the found events with the results kept from the previous run of the saved search, then keeps the found events for the next run. Results that may be missing events, because the search was cancelled or a request failed, are not kept and removed events are not reported as they may only be missing.
+Parameters:
+- w: io.Writer: where the summary of the changes and warnings are written.
+- rows: []output.Row: the found events and their clash status.
+- complete: bool: true if every request of the search succeeded.
+Returns:
+- []output.Row: the rows to write, only the new, changed and removed events when newOnly is set.
+*/
+func (r *savedRun) compare(w io.Writer, db *sql.DB, rows []output.Row, complete bool) []output.Row {
+	current := make([]eventsearch.FoundEvent, len(rows))
+	for i, row := range rows {
+		current[i] = row.Event
+	}
+	// the previous results are read before they are replaced by the results of this run
+	var previous []database.SnapshotEvent
+	var err error
+	if r.newOnly {
+		previous, err = database.GetSnapshot(db, r.search.ID)
+		if err != nil {
+			fmt.Fprintf(w, "Warning: %s, showing every event\n", err)
+			r.newOnly = false
+		}
+	}
+	switch {
+	case r.overridden:
+		fmt.Fprintf(w, "The results are not kept for the next run of %s as search flags replaced its saved parameters.\n", r.search.Name)
+	case !complete:
+		fmt.Fprintf(w, "Warning: the search did not complete, the results are not kept for the next run of %s.\n", r.search.Name)
+	default:
+		if err := database.ReplaceSnapshot(db, r.search.ID, toSnapshot(current)); err != nil {
+			fmt.Fprintf(w, "Warning: failed to keep the results of %s, the next run is compared with the previous results: %s\n", r.search.Name, err)
+		}
+	}
+	if !r.newOnly {
+		return rows
+	}
+
+	var previousEvents []eventsearch.FoundEvent
+	for _, event := range previous {
+		previousEvents = append(previousEvents, fromSnapshot(event))
+	}
+	// rows are found by ref to keep the clash status of new and changed events
+	byRef := make(map[string]output.Row)
+	for _, row := range rows {
+		byRef[row.Event.Ref()] = row
+	}
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	var changedRows []output.Row
+	counts := make(map[changes.Status]int)
+	for _, change := range changes.Diff(previousEvents, current, today) {
+		row := byRef[change.Event.Ref()]
+		if change.Status == changes.Removed {
+			// an event missing from incomplete results may still be listed
+			if !complete {
+				continue
+			}
+			row = output.Row{Event: change.Event}
+		}
+		row.Change = change
+		changedRows = append(changedRows, row)
+		counts[change.Status]++
+	}
+	if r.search.SnapshotAt.IsZero() {
+		fmt.Fprintf(w, "First run of %s, every event is new.\n\n", r.search.Name)
+	} else {
+		fmt.Fprintf(w, "%d new, %d changed and %d removed since the run on %s.\n\n", counts[changes.New], counts[changes.Changed], counts[changes.Removed], r.search.SnapshotAt.Local().Format("2006-01-02 15:04"))
+	}
+	return changedRows
+}
+
+/*
+Reports whether a search found every event it could, it was not cancelled and no city or request failed.
+*/
+func searchComplete(ctx context.Context, result eventsearch.SearchResult) bool {
+	if ctx.Err() != nil || len(result.Warnings) > 0 {
+		return false
+	}
+	for _, report := range result.Providers {
+		if report.Failed > 0 || len(report.Errors) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// converts found events to the events kept for the next run of a saved search
+func toSnapshot(events []eventsearch.FoundEvent) []database.SnapshotEvent {
+	var snapshot []database.SnapshotEvent
+	for _, event := range events {
+		snapshotEvent := database.SnapshotEvent{
+			Fingerprint: event.Fingerprint(),
+			EventName:   event.Name,
+			Date:        event.Date,
+			Start:       event.Start,
+			End:         event.End,
+			City:        event.City,
+			Venue:       event.Venue,
+			Genre:       output.GenreText(event),
+		}
+		for _, source := range event.Sources {
+			snapshotEvent.Providers = append(snapshotEvent.Providers, source.Provider)
+			snapshotEvent.Tickets = append(snapshotEvent.Tickets, source.Tickets)
+		}
+		if len(event.Sources) == 0 {
+			snapshotEvent.Providers, snapshotEvent.Tickets = []string{event.Provider}, []string{event.Tickets}
+		}
+		snapshot = append(snapshot, snapshotEvent)
+	}
+	return snapshot
+}
+
+// converts an event kept from the previous run of a saved search back to a found event
+func fromSnapshot(event database.SnapshotEvent) eventsearch.FoundEvent {
+	foundEvent := eventsearch.FoundEvent{
+		Name:  event.EventName,
+		Date:  event.Date,
+		Start: event.Start,
+		End:   event.End,
+		City:  event.City,
+		Venue: event.Venue,
+		Genre: event.Genre,
+	}
+	for i, provider := range event.Providers {
+		source := eventsearch.EventSource{Provider: provider}
+		if i < len(event.Tickets) {
+			source.Tickets = event.Tickets[i]
+		}
+		foundEvent.Sources = append(foundEvent.Sources, source)
+	}
+	if len(foundEvent.Sources) > 0 {
+		foundEvent.Provider, foundEvent.Tickets = foundEvent.Sources[0].Provider, foundEvent.Sources[0].Tickets
+	}
+	return foundEvent
+}
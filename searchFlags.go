@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/ben-23-96/go_events_cli/config"
+	"github.com/ben-23-96/go_events_cli/eventsearch"
+	"github.com/ben-23-96/go_events_cli/output"
+)
+
+// the values of the search flags, the search parameters are set directly on the ApiSearch
+type searchOptions struct {
+	search       eventsearch.ApiSearch
+	providers    string
+	outputFormat string
+	verbose      bool
+	interactive  bool
+	calendars    string
+}
+
+/*
+Defines the flags of the search command, shared by search, search save and search run. The defaults come from the config file.
+Parameters:
+- name: string: the name of the flag set, eg "search save".
+- cfg: *config.Config: the config giving the defaults.
+Returns:
+- *flag.FlagSet: the flags.
+- *searchOptions: the values the flags are parsed into.
+*/
+func newSearchFlags(name string, cfg *config.Config) (*flag.FlagSet, *searchOptions) {
+	eventSearchCmd := flag.NewFlagSet(name, flag.ExitOnError)
+	opts := &searchOptions{}
+	eventSearchCmd.StringVar(&opts.search.Cities, "cities", configValue(cfg, "search.cities"), "Indivual city or comma seperated list of cities. Example: \"Manchester,Brisol\"")
+	eventSearchCmd.StringVar(&opts.search.Genres, "genres", configValue(cfg, "search.genres"), "Indivual genre or subgenre comma seperated list. Example: \"Music,Sport\" Example2: \"Techno,Football\"")
+	eventSearchCmd.StringVar(&opts.search.DateFrom, "date-from", "today", "Date to start searching from in format YYYY-MM-DD or a relative date: today, tomorrow, this-weekend, next-weekend, next-friday, +2w. Default current date.")
+	eventSearchCmd.StringVar(&opts.search.DateTo, "date-to", configValue(cfg, "search.lookahead"), "Date to start searching to in format YYYY-MM-DD or a relative date: today, tomorrow, this-weekend, next-weekend, next-friday, +2w. Default search.lookahead from the config, else 1 month from current date.")
+	eventSearchCmd.StringVar(&opts.providers, "providers", configValue(cfg, "search.providers"), "Event providers to search, comma seperated list. Example: \"ticketmaster,skiddle\"")
+	eventSearchCmd.IntVar(&opts.search.MaxResults, "max-results", eventsearch.DefaultMaxResults, "Maximum number of results to collect from the pages of each provider request.")
+	eventSearchCmd.DurationVar(&opts.search.RequestTimeout, "timeout", eventsearch.DefaultRequestTimeout, "Time allowed for each API request before it is abandoned. Example: \"30s\"")
+	eventSearchCmd.BoolVar(&opts.search.KeepDuplicates, "keep-duplicates", false, "Display every listing of an event rather than merging the same event found on several providers.")
+	eventSearchCmd.StringVar(&opts.outputFormat, "output", configValue(cfg, "search.output"), "Output format of the found events: "+strings.Join(output.Formats, ", ")+".")
+	eventSearchCmd.BoolVar(&opts.verbose, "verbose", false, "Display the matched genres, geocoded cities and request timings of the search.")
+	eventSearchCmd.BoolVar(&opts.interactive, "interactive", false, "Browse the found events in an interactive list, filter them and add them to the calendar.")
+	eventSearchCmd.StringVar(&opts.calendars, "calendars", "", "Calendars to check the found events for clashes against, comma seperated list or all. Default the calendar chosen with -calendar.")
+	return eventSearchCmd, opts
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ben-23-96/go_events_cli/database"
+	"github.com/ben-23-96/go_events_cli/subscriptions"
+)
+
+/*
+Handles the calendar subscribe subcommand. Stores a subscription to an iCalendar url or file and caches its events so they are checked for clashes when searching.
+Parameters:
+- args: the arguments after subscribe, the name and the url or path of the calendar.
+*/
+func handleCalendarSubscribeCmd(args []string) {
+	subscribeCmd := flag.NewFlagSet("calendar subscribe", flag.ExitOnError)
+	subscribeCmd.Usage = func() {
+		fmt.Fprintln(subscribeCmd.Output(), "Usage: calendar subscribe <name> <ics-url-or-path>")
+	}
+	subscribeCmd.Parse(args)
+	if subscribeCmd.NArg() != 2 {
+		subscribeCmd.Usage()
+		os.Exit(2)
+	}
+
+	db, err := database.InitDB()
+	if err != nil {
+		fmt.Printf("error initializing database: %s\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	subscription, err := database.AddSubscription(db, subscribeCmd.Arg(0), subscriptions.Source(subscribeCmd.Arg(1)))
+	if err != nil {
+		fmt.Printf("Subscription not added: %s\n", err)
+		os.Exit(1)
+	}
+	// the subscription is kept when the first refresh fails so a calendar that is temporarily unavailable can be retried
+	count, err := subscriptions.Refresh(context.Background(), db, subscription)
+	if err != nil {
+		fmt.Printf("Subscribed to %s but %s, it will be retried on the next search\n", subscription.Name, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Subscribed to %s, %d events cached\n", subscription.Name, count)
+}
+
+/*
+Handles the calendar unsubscribe subcommand, deleting the subscription and its cached events.
+Parameters:
+- args: the arguments after unsubscribe, the name of the subscription.
+*/
+func handleCalendarUnsubscribeCmd(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: calendar unsubscribe <name>")
+		os.Exit(2)
+	}
+	db, err := database.InitDB()
+	if err != nil {
+		fmt.Printf("error initializing database: %s\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	if err := database.DeleteSubscription(db, args[0]); err != nil {
+		fmt.Printf("Subscription not deleted: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Unsubscribed from %s\n", args[0])
+}
+
+/*
+Handles the calendar subscriptions subcommand, listing each subscription with when it was last refreshed.
+*/
+func handleCalendarSubscriptionsCmd(args []string) {
+	db, err := database.InitDB()
+	if err != nil {
+		fmt.Printf("error initializing database: %s\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	list, err := database.GetSubscriptions(db)
+	if err != nil {
+		fmt.Printf("Error retrieving subscriptions from database. Err: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Print("Subscriptions:\n\n")
+	for _, subscription := range list {
+		refreshed := "never refreshed"
+		if !subscription.RefreshedAt.IsZero() {
+			refreshed = "refreshed " + subscription.RefreshedAt.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%s    %s    %d events, %s\n", subscription.Name, subscription.Source, subscription.Events, refreshed)
+		if subscription.LastError != "" {
+			fmt.Printf("    last refresh failed: %s\n", subscription.LastError)
+		}
+	}
+}
+
+/*
+Handles the calendar refresh subcommand. Refreshes the named subscriptions now, or every subscription if no names are given.
+Parameters:
+- args: the arguments after refresh, the names of the subscriptions to refresh.
+*/
+func handleCalendarRefreshCmd(args []string) {
+	db, err := database.InitDB()
+	if err != nil {
+		fmt.Printf("error initializing database: %s\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	var list []database.Subscription
+	if len(args) == 0 {
+		list, err = database.GetSubscriptions(db)
+		if err != nil {
+			fmt.Printf("Error retrieving subscriptions from database. Err: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	for _, name := range args {
+		subscription, err := database.GetSubscription(db, name)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		list = append(list, subscription)
+	}
+
+	failed := 0
+	for _, subscription := range list {
+		count, err := subscriptions.Refresh(context.Background(), db, subscription)
+		if err != nil {
+			fmt.Println(err)
+			failed++
+			continue
+		}
+		fmt.Printf("Refreshed %s, %d events cached\n", subscription.Name, count)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+/*
+Refreshes the subscriptions whose cached events are older than the refresh interval and returns the cached events of every subscription in the range of the filter. Subscriptions that fail to refresh keep their cached events, the errors are returned as warnings.
+Parameters:
+- filter: database.ListFilter: the range of the search, repeating events are expanded to their occurrences in it.
+Returns:
+- []database.SubscriptionEvent: the cached events of every subscription.
+- []error: warnings from refreshing or reading the subscriptions.
+*/
+func subscriptionBusyEvents(ctx context.Context, db *sql.DB, filter database.ListFilter) ([]database.SubscriptionEvent, []error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	warnings := subscriptions.RefreshStale(ctx, db, subscriptions.DefaultRefreshInterval)
+	events, err := database.GetSubscriptionEvents(db, filter.From, filter.To)
+	if err != nil {
+		warnings = append(warnings, err)
+	}
+	return events, warnings
+}
@@ -0,0 +1,159 @@
+package subscriptions
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ben-23-96/go_events_cli/database"
+	"github.com/ben-23-96/go_events_cli/ics"
+)
+
+// how long the cached events of a subscription are used before they are refreshed
+const DefaultRefreshInterval = time.Hour
+
+// time allowed to download a subscription
+const fetchTimeout = 20 * time.Second
+
+/*
+Fetch reads the events of an iCalendar file from a http(s) url, a file:// url or a file path.
+Parameters:
+- ctx: context.Context: cancels the download.
+- source: string: the url or path of the file.
+Returns:
+- []database.SubscriptionEvent: the events in the file.
+- error: if the file could not be read or is not a valid iCalendar file.
+*/
+func Fetch(ctx context.Context, source string) ([]database.SubscriptionEvent, error) {
+	body, err := open(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	icsEvents, err := ics.Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid calendar file: %v", err)
+	}
+	var events []database.SubscriptionEvent
+	for _, event := range ics.ApplyOverrides(icsEvents) {
+		events = append(events, database.SubscriptionEvent{
+			UID:       event.UID,
+			EventName: event.Summary,
+			Start:     event.Start,
+			End:       event.End,
+			AllDay:    event.AllDay,
+			RRule:     event.RRule,
+			ExDates:   event.ExDates,
+		})
+	}
+	return events, nil
+}
+
+/*
+Source returns the form of a subscription source that is stored, file paths are made absolute so the subscription can be refreshed from any directory.
+*/
+func Source(source string) string {
+	if isURL(source) || strings.HasPrefix(source, "file://") {
+		return source
+	}
+	if abs, err := filepath.Abs(source); err == nil {
+		return abs
+	}
+	return source
+}
+
+// reports whether the source is downloaded rather than read from a file
+func isURL(source string) bool {
+	for _, scheme := range []string{"http://", "https://", "webcal://"} {
+		if strings.HasPrefix(source, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// opens the source for reading, webcal urls are fetched over https
+func open(ctx context.Context, source string) (io.ReadCloser, error) {
+	if strings.HasPrefix(source, "webcal://") {
+		source = "https://" + strings.TrimPrefix(source, "webcal://")
+	}
+	if !isURL(source) {
+		return os.Open(strings.TrimPrefix(source, "file://"))
+	}
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return cancelOnClose{ReadCloser: res.Body, cancel: cancel}, nil
+}
+
+// releases the timeout of a download when its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+/*
+Refresh downloads the events of a subscription and replaces its cached events. When the download fails the error is recorded and the cached events of the last successful refresh are kept.
+Returns:
+- int: the number of events cached.
+- error: if the download or caching failed.
+*/
+func Refresh(ctx context.Context, db *sql.DB, subscription database.Subscription) (int, error) {
+	events, err := Fetch(ctx, subscription.Source)
+	if err == nil {
+		err = database.ReplaceSubscriptionEvents(db, subscription.ID, events)
+	}
+	if err != nil {
+		database.SetSubscriptionError(db, subscription.ID, err)
+		return 0, fmt.Errorf("failed to refresh subscription %s: %v", subscription.Name, err)
+	}
+	return len(events), nil
+}
+
+/*
+RefreshStale refreshes every subscription that has not been refreshed within maxAge.
+Parameters:
+- maxAge: time.Duration: how old cached events can be before they are refreshed.
+Returns:
+- []error: the errors of the subscriptions that failed to refresh, they keep their cached events.
+*/
+func RefreshStale(ctx context.Context, db *sql.DB, maxAge time.Duration) []error {
+	subscriptions, err := database.GetSubscriptions(db)
+	if err != nil {
+		return []error{err}
+	}
+	var errs []error
+	for _, subscription := range subscriptions {
+		if time.Since(subscription.RefreshedAt) < maxAge {
+			continue
+		}
+		if _, err := Refresh(ctx, db, subscription); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
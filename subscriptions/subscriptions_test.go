@@ -0,0 +1,173 @@
+package subscriptions
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ben-23-96/go_events_cli/database"
+)
+
+// a feed with a reminder on the standup, the properties of the VALARM must not be read as the standup's
+const testCalendar = `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:standup@example.com
+SUMMARY:Standup
+DTSTART:20261020T100000
+DTEND:20261020T110000
+RRULE:FREQ=WEEKLY;COUNT=4
+BEGIN:VALARM
+ACTION:DISPLAY
+SUMMARY:Alarm notification
+DESCRIPTION:This is an event reminder
+TRIGGER:-PT10M
+DURATION:PT5M
+REPEAT:1
+END:VALARM
+EXDATE:20261027T100000
+END:VEVENT
+BEGIN:VEVENT
+UID:holiday@example.com
+SUMMARY:Holiday
+DTSTART;VALUE=DATE:20261101
+DTEND;VALUE=DATE:20261102
+END:VEVENT
+END:VCALENDAR
+`
+
+// opens a calendar in a temporary directory
+func openTestCalendar(t *testing.T) *sql.DB {
+	t.Helper()
+	if err := database.SetLocation(t.TempDir(), "test"); err != nil {
+		t.Fatal(err)
+	}
+	db, err := database.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// checks the events of testCalendar were read with the repeat rule of the standup
+func checkTestEvents(t *testing.T, events []database.SubscriptionEvent) {
+	t.Helper()
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	standup, holiday := events[0], events[1]
+	if standup.EventName != "Standup" || standup.UID != "standup@example.com" {
+		t.Errorf("first event = %s %s, want Standup standup@example.com", standup.EventName, standup.UID)
+	}
+	if want := time.Date(2026, 10, 20, 10, 0, 0, 0, time.UTC); !standup.Start.Equal(want) || standup.AllDay {
+		t.Errorf("standup start = %s all day %v, want %s", standup.Start, standup.AllDay, want)
+	}
+	if want := time.Date(2026, 10, 20, 11, 0, 0, 0, time.UTC); !standup.End.Equal(want) {
+		t.Errorf("standup end = %s, want %s", standup.End, want)
+	}
+	if standup.RRule != "FREQ=WEEKLY;COUNT=4" || len(standup.ExDates) != 1 {
+		t.Errorf("standup rule = %q except %v, want FREQ=WEEKLY;COUNT=4 except one date", standup.RRule, standup.ExDates)
+	}
+	if holiday.EventName != "Holiday" || !holiday.AllDay {
+		t.Errorf("second event = %s all day %v, want an all day Holiday", holiday.EventName, holiday.AllDay)
+	}
+}
+
+func TestFetchHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/work.ics" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar")
+		w.Write([]byte(testCalendar))
+	}))
+	defer server.Close()
+
+	events, err := Fetch(context.Background(), server.URL+"/work.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestEvents(t, events)
+
+	if _, err := Fetch(context.Background(), server.URL+"/missing.ics"); err == nil {
+		t.Error("fetching a missing calendar succeeded, want an error")
+	}
+}
+
+func TestFetchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "work.ics")
+	if err := os.WriteFile(path, []byte(testCalendar), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, source := range []string{path, "file://" + path} {
+		events, err := Fetch(context.Background(), source)
+		if err != nil {
+			t.Fatalf("%s: %v", source, err)
+		}
+		checkTestEvents(t, events)
+	}
+
+	if _, err := Fetch(context.Background(), filepath.Join(t.TempDir(), "missing.ics")); err == nil {
+		t.Error("fetching a missing file succeeded, want an error")
+	}
+}
+
+func TestRefreshReplacesCachedEvents(t *testing.T) {
+	db := openTestCalendar(t)
+	path := filepath.Join(t.TempDir(), "work.ics")
+	if err := os.WriteFile(path, []byte(testCalendar), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	subscription, err := database.AddSubscription(db, "work", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, err := Refresh(context.Background(), db, subscription); err != nil || count != 2 {
+		t.Fatalf("first refresh = %d, %v, want 2 events", count, err)
+	}
+
+	// the feed drops the holiday, the refresh must not keep it
+	updated := `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:review@example.com
+SUMMARY:Review
+DTSTART:20261021T140000
+END:VEVENT
+END:VCALENDAR
+`
+	if err := os.WriteFile(path, []byte(updated), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := Refresh(context.Background(), db, subscription); err != nil || count != 1 {
+		t.Fatalf("second refresh = %d, %v, want 1 event", count, err)
+	}
+	events, err := database.GetSubscriptionEvents(db, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].EventName != "Review" || events[0].Subscription != "work" {
+		t.Fatalf("cached events after refresh = %+v, want only Review from work", events)
+	}
+
+	// a failed refresh keeps the events of the last successful one and records the error
+	os.Remove(path)
+	if _, err := Refresh(context.Background(), db, subscription); err == nil {
+		t.Fatal("refresh of a missing file succeeded, want an error")
+	}
+	events, _ = database.GetSubscriptionEvents(db, time.Time{}, time.Time{})
+	if len(events) != 1 {
+		t.Errorf("got %d cached events after a failed refresh, want 1", len(events))
+	}
+	stored, _ := database.GetSubscription(db, "work")
+	if stored.LastError == "" {
+		t.Error("failed refresh did not record its error")
+	}
+}
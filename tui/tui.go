@@ -0,0 +1,478 @@
+package tui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ben-23-96/go_events_cli/clash"
+	"github.com/ben-23-96/go_events_cli/eventsearch"
+	"github.com/ben-23-96/go_events_cli/output"
+	"golang.org/x/term"
+)
+
+// AddFunc adds a found event to the calendar and returns a message to show, eg the id of the new calendar event.
+type AddFunc func(event eventsearch.FoundEvent) (string, error)
+
+// ErrNotTerminal is returned by Run when stdin or stdout is not a terminal.
+var ErrNotTerminal = errors.New("interactive mode needs a terminal")
+
+// ansi escape codes used to draw the screen
+const (
+	enterScreen = "\x1b[?1049h\x1b[?25l"
+	leaveScreen = "\x1b[?25h\x1b[?1049l"
+	clearScreen = "\x1b[H\x1b[2J"
+	reverse     = "\x1b[7m"
+	bold        = "\x1b[1m"
+	red         = "\x1b[31m"
+	yellow      = "\x1b[33m"
+	green       = "\x1b[32m"
+	reset       = "\x1b[0m"
+)
+
+// number of lines of the detail pane below the list
+const detailHeight = 8
+
+// a key read from the terminal
+type key int
+
+const (
+	keyRune key = iota
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyEnter
+	keyEscape
+	keyBackspace
+	keyInterrupt
+	keyUnknown
+)
+
+/*
+Run shows the rows in a full screen list until the user quits. The list can be scrolled, filtered by typing after /, shows the details of the selected event and adds the selected event to the calendar with a.
+Parameters:
+- rows: []output.Row: the found events and their clash status, shown sorted by start.
+- add: AddFunc: called when the user adds the selected event.
+Returns:
+- error: ErrNotTerminal if the program is not running in a terminal, or an error reading from or writing to it.
+*/
+func Run(rows []output.Row, add AddFunc) error {
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		return ErrNotTerminal
+	}
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(in, state)
+	fmt.Print(enterScreen)
+	defer fmt.Print(leaveScreen)
+
+	m := newModel(rows, add)
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		width, height, err := term.GetSize(out)
+		if err != nil {
+			width, height = 80, 24
+		}
+		writer := bufio.NewWriter(os.Stdout)
+		m.render(writer, width, height)
+		writer.Flush()
+
+		k, r, err := readKey(reader)
+		if err != nil {
+			return err
+		}
+		if m.handleKey(k, r, height) {
+			return nil
+		}
+	}
+}
+
+// model is the state of the interactive list
+type model struct {
+	rows []output.Row
+	add  AddFunc
+	// indexes into rows of the events that match the filter
+	visible []int
+	// position of the selected event in visible and of the first event shown
+	cursor int
+	offset int
+	filter string
+	// true while the filter is being typed
+	filtering bool
+	// indexes into rows of the events added to the calendar
+	added map[int]bool
+	// message shown in the footer after an action
+	status string
+}
+
+// creates the model with the rows sorted by start
+func newModel(rows []output.Row, add AddFunc) *model {
+	sorted := append([]output.Row{}, rows...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return eventStart(sorted[i].Event).Before(eventStart(sorted[j].Event))
+	})
+	m := &model{rows: sorted, add: add, added: map[int]bool{}}
+	m.applyFilter()
+	return m
+}
+
+// the start of an event, midnight on its date when the time is not known
+func eventStart(event eventsearch.FoundEvent) time.Time {
+	if event.Start.IsZero() {
+		return event.Date
+	}
+	return event.Start
+}
+
+// updates the visible events to those matching the filter, keeping the cursor in range
+func (m *model) applyFilter() {
+	m.visible = m.visible[:0]
+	filter := strings.ToLower(m.filter)
+	for i, row := range m.rows {
+		if filter == "" || strings.Contains(searchText(row.Event), filter) {
+			m.visible = append(m.visible, i)
+		}
+	}
+	m.cursor = max(0, min(m.cursor, len(m.visible)-1))
+	m.offset = min(m.offset, m.cursor)
+}
+
+// the lower case text of an event that the filter is matched against
+func searchText(event eventsearch.FoundEvent) string {
+	text := []string{event.Name, event.City, event.Genre, event.Subgenre, event.Provider}
+	for _, source := range event.Sources {
+		text = append(text, source.Provider)
+	}
+	return strings.ToLower(strings.Join(text, " "))
+}
+
+/*
+Updates the model for a key press.
+Returns:
+- bool: true when the user quits.
+*/
+func (m *model) handleKey(k key, r rune, height int) bool {
+	if k == keyInterrupt {
+		return true
+	}
+	page := max(1, listHeight(height)-1)
+	if m.filtering {
+		switch k {
+		case keyEnter:
+			m.filtering = false
+		case keyEscape:
+			m.filtering = false
+			m.filter = ""
+		case keyBackspace:
+			if m.filter != "" {
+				_, size := utf8.DecodeLastRuneInString(m.filter)
+				m.filter = m.filter[:len(m.filter)-size]
+			}
+		case keyRune:
+			m.filter += string(r)
+		case keyUp, keyDown, keyPageUp, keyPageDown, keyHome, keyEnd:
+			m.move(k, page)
+			return false
+		}
+		m.applyFilter()
+		return false
+	}
+
+	m.status = ""
+	switch {
+	case k == keyRune && r == 'q':
+		return true
+	case k == keyRune && r == '/':
+		m.filtering = true
+	case k == keyEscape && m.filter != "":
+		m.filter = ""
+		m.applyFilter()
+	case k == keyRune && r == 'a', k == keyEnter:
+		m.addSelected()
+	case k == keyRune && r == 'j':
+		m.move(keyDown, page)
+	case k == keyRune && r == 'k':
+		m.move(keyUp, page)
+	case k == keyRune && r == 'g':
+		m.move(keyHome, page)
+	case k == keyRune && r == 'G':
+		m.move(keyEnd, page)
+	default:
+		m.move(k, page)
+	}
+	return false
+}
+
+// moves the cursor for a navigation key, other keys are ignored
+func (m *model) move(k key, page int) {
+	switch k {
+	case keyUp:
+		m.cursor--
+	case keyDown:
+		m.cursor++
+	case keyPageUp:
+		m.cursor -= page
+	case keyPageDown:
+		m.cursor += page
+	case keyHome:
+		m.cursor = 0
+	case keyEnd:
+		m.cursor = len(m.visible) - 1
+	}
+	m.cursor = max(0, min(m.cursor, len(m.visible)-1))
+}
+
+// adds the selected event to the calendar and reports the outcome in the footer
+func (m *model) addSelected() {
+	if len(m.visible) == 0 {
+		return
+	}
+	index := m.visible[m.cursor]
+	if m.added[index] {
+		m.status = "already added"
+		return
+	}
+	message, err := m.add(m.rows[index].Event)
+	if err != nil {
+		m.status = red + "not added: " + err.Error() + reset
+		return
+	}
+	m.added[index] = true
+	m.status = green + message + reset
+}
+
+// number of list lines that fit above the detail pane, with a line for the header and the footer
+func listHeight(height int) int {
+	return max(1, height-detailHeight-3)
+}
+
+// draws the whole screen
+func (m *model) render(w io.Writer, width int, height int) {
+	lines := listHeight(height)
+	// scroll so the cursor is on screen
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+lines {
+		m.offset = m.cursor - lines + 1
+	}
+
+	fmt.Fprint(w, clearScreen)
+	header := fmt.Sprintf("%d of %d events", len(m.visible), len(m.rows))
+	if m.filtering || m.filter != "" {
+		header += "  filter: " + m.filter
+		if m.filtering {
+			header += "_"
+		}
+	}
+	writeLine(w, bold+fit(header, width)+reset)
+
+	for i := m.offset; i < m.offset+lines; i++ {
+		if i >= len(m.visible) {
+			writeLine(w, "")
+			continue
+		}
+		index := m.visible[i]
+		text := fit(listText(m.rows[index], m.added[index]), width)
+		color := clashColor(m.rows[index].Clash.Status)
+		if i == m.cursor {
+			writeLine(w, reverse+color+padRight(text, width)+reset)
+		} else {
+			writeLine(w, color+text+reset)
+		}
+	}
+
+	writeLine(w, strings.Repeat("─", width))
+	details := make([]string, detailHeight-1)
+	if len(m.visible) > 0 {
+		details = detailLines(m.rows[m.visible[m.cursor]])
+	}
+	for i := 0; i < detailHeight-1; i++ {
+		line := ""
+		if i < len(details) {
+			line = details[i]
+		}
+		writeLine(w, fit(line, width))
+	}
+
+	footer := "↑/↓ move  / filter  a add to calendar  q quit"
+	if m.filtering {
+		footer = "type to filter  enter done  esc clear"
+	}
+	if m.status != "" {
+		footer = m.status
+	}
+	fmt.Fprint(w, fit(footer, width))
+}
+
+// the line of the list for an event
+func listText(row output.Row, added bool) string {
+	marker := "  "
+	if added {
+		marker = "+ "
+	}
+	when := row.Event.Date.Format("Mon 2006-01-02")
+	if !row.Event.Start.IsZero() {
+		when = row.Event.Start.Format("Mon 2006-01-02 15:04")
+	}
+	clashMark := ""
+	switch row.Clash.Status {
+	case clash.Overlap:
+		clashMark = "  [clash]"
+	case clash.SameDay:
+		clashMark = "  [same day]"
+	}
+	return fmt.Sprintf("%s%-20s  %s  (%s)%s", marker, when, row.Event.Name, row.Event.City, clashMark)
+}
+
+// the lines of the detail pane for an event
+func detailLines(row output.Row) []string {
+	event := row.Event
+	when := event.Date.Format("Monday 2 January 2006")
+	if !event.Start.IsZero() {
+		when = event.Start.Format("Monday 2 January 2006 15:04")
+		if !event.End.IsZero() {
+			when += " - " + event.End.Format("15:04")
+		}
+	}
+	lines := []string{
+		bold + event.Name + reset,
+		"When:    " + when,
+		"City:    " + event.City,
+		"Genre:   " + output.GenreText(event),
+	}
+	sources := event.Sources
+	if len(sources) == 0 {
+		sources = []eventsearch.EventSource{{Provider: event.Provider, Tickets: event.Tickets}}
+	}
+	for _, source := range sources {
+		lines = append(lines, fmt.Sprintf("Tickets: %s %s", source.Provider, source.Tickets))
+	}
+	switch row.Clash.Status {
+	case clash.Overlap:
+		lines = append(lines, red+"Clashes with: "+periodNames(row.Clash.Clashes)+reset)
+	case clash.SameDay:
+		lines = append(lines, yellow+"Same day as: "+periodNames(row.Clash.SameDay)+reset)
+	}
+	return lines
+}
+
+// the names of busy periods seperated by commas
+func periodNames(periods []clash.BusyPeriod) string {
+	var names []string
+	for _, period := range periods {
+		names = append(names, period.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// the colour of a list line for a clash status
+func clashColor(status clash.Status) string {
+	switch status {
+	case clash.Overlap:
+		return red
+	case clash.SameDay:
+		return yellow
+	}
+	return ""
+}
+
+// writes a line of the screen, raw mode needs a carriage return to go back to the start of the line
+func writeLine(w io.Writer, line string) {
+	fmt.Fprint(w, line+"\r\n")
+}
+
+// truncates text to the width of the terminal, escape codes are not counted
+func fit(text string, width int) string {
+	visible := 0
+	inEscape := false
+	for i, r := range text {
+		switch {
+		case r == '\x1b':
+			inEscape = true
+		case inEscape:
+			if r == 'm' {
+				inEscape = false
+			}
+		default:
+			visible++
+			if visible > width {
+				return text[:i] + reset
+			}
+		}
+	}
+	return text
+}
+
+// pads text with spaces to the width so the highlight of the selected line fills the row
+func padRight(text string, width int) string {
+	if n := utf8.RuneCountInString(text); n < width {
+		return text + strings.Repeat(" ", width-n)
+	}
+	return text
+}
+
+/*
+Reads a key press from the terminal, decoding the escape sequences of the arrow and page keys.
+Returns:
+- key: the key pressed.
+- rune: the character typed when the key is keyRune.
+*/
+func readKey(reader *bufio.Reader) (key, rune, error) {
+	r, _, err := reader.ReadRune()
+	if err != nil {
+		return keyUnknown, 0, err
+	}
+	switch r {
+	case 3, 4:
+		return keyInterrupt, 0, nil
+	case '\r', '\n':
+		return keyEnter, 0, nil
+	case 127, 8:
+		return keyBackspace, 0, nil
+	case '\x1b':
+		// a lone escape has nothing buffered after it
+		if reader.Buffered() == 0 {
+			return keyEscape, 0, nil
+		}
+		sequence := make([]byte, 0, 4)
+		for reader.Buffered() > 0 && len(sequence) < 4 {
+			b, _ := reader.ReadByte()
+			sequence = append(sequence, b)
+			if (b >= 'A' && b <= 'Z') || b == '~' {
+				break
+			}
+		}
+		switch string(sequence) {
+		case "[A", "OA":
+			return keyUp, 0, nil
+		case "[B", "OB":
+			return keyDown, 0, nil
+		case "[5~":
+			return keyPageUp, 0, nil
+		case "[6~":
+			return keyPageDown, 0, nil
+		case "[H", "OH", "[1~":
+			return keyHome, 0, nil
+		case "[F", "OF", "[4~":
+			return keyEnd, 0, nil
+		}
+		return keyUnknown, 0, nil
+	}
+	if r < ' ' {
+		return keyUnknown, 0, nil
+	}
+	return keyRune, r, nil
+}